@@ -8,14 +8,20 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/krzyzao/kub/internal/alerts"
 	"github.com/krzyzao/kub/internal/api"
+	"github.com/krzyzao/kub/internal/edithistory"
 	"github.com/krzyzao/kub/internal/k8s"
+	"github.com/krzyzao/kub/internal/logarchive"
+	"github.com/krzyzao/kub/internal/models"
+	"github.com/krzyzao/kub/internal/telemetry"
 )
 
 //go:embed static/*
@@ -28,16 +34,65 @@ func main() {
 		log.Fatalf("Failed to create K8s client: %v", err)
 	}
 
+	// Metrics come from metrics-server unless KUB_PROMETHEUS_URL has a
+	// Prometheus for the context
+	promConfig, err := k8s.PrometheusConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid Prometheus configuration: %v", err)
+	}
+	if len(promConfig.URLs) > 0 {
+		if err := k8sClient.UsePrometheus(promConfig); err != nil {
+			log.Fatalf("Invalid Prometheus configuration: %v", err)
+		}
+		for contextName, u := range promConfig.URLs {
+			if contextName == "" {
+				contextName = k8sClient.RawConfig.CurrentContext
+			}
+			log.Printf("Using Prometheus metrics at %s for context %s", u, contextName)
+		}
+	}
+
+	// Local log archive; collection is opt-in through KUB_LOG_ARCHIVE
+	archiveConfig, err := logarchive.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid log archive configuration: %v", err)
+	}
+	logArchive := logarchive.NewStore(filepath.Join(edithistory.DataDir(), "logs"), archiveConfig.MaxBytes, archiveConfig.MaxAge)
+
 	// Create handlers
-	handler := api.NewHandler(k8sClient)
 	hub := api.NewHub(k8sClient)
-	logStreamHub := api.NewLogStreamHub(k8sClient)
+	handler := api.NewHandler(k8sClient, hub, logArchive)
+	logStreamHub := api.NewLogStreamHub(k8sClient, logArchive)
+	execHub := api.NewExecHub(k8sClient)
+	portForwards := api.NewPortForwardManager(k8sClient, hub)
+
+	// Log alert rules, sent over /ws and optionally to KUB_ALERT_WEBHOOK
+	var alertEngine *alerts.Engine
+	if path := os.Getenv("KUB_ALERT_RULES"); path != "" {
+		rules, err := alerts.LoadRules(path)
+		if err != nil {
+			log.Fatalf("Invalid alert rules: %v", err)
+		}
+		notifiers := []alerts.Notifier{hub.PublishAlert}
+		if webhookURL := os.Getenv("KUB_ALERT_WEBHOOK"); webhookURL != "" {
+			webhook, err := alerts.NewWebhook(webhookURL)
+			if err != nil {
+				log.Fatalf("Invalid alert webhook: %v", err)
+			}
+			notifiers = append(notifiers, webhook)
+		}
+		if alertEngine, err = alerts.NewEngine(k8sClient, rules, notifiers...); err != nil {
+			log.Fatalf("Invalid alert rules: %v", err)
+		}
+	}
 
 	// Create router
 	r := chi.NewRouter()
 
 	// Rate limiter: 100 requests/second, burst of 200
 	rateLimiter := api.NewRateLimiter(100, 200)
+	// Request metrics first, so rate-limited requests are counted too
+	r.Use(telemetry.Middleware)
 	r.Use(rateLimiter.RateLimitMiddleware)
 
 	// Security headers middleware
@@ -56,7 +111,7 @@ func main() {
 	r.Use(middleware.Compress(5))
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   api.GetAllowedOrigins(),
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
 		AllowCredentials: true,
 		MaxAge:           300,
@@ -68,28 +123,69 @@ func main() {
 		r.Get("/pods", handler.GetPods)
 		r.Get("/pods/paginated", handler.GetPodsPaginated)
 		r.Get("/pods/{namespace}/{name}", handler.GetPod)
+		r.Delete("/pods/{namespace}/{name}", handler.DeletePod)
+		r.Post("/pods/{namespace}/{name}/evict", handler.EvictPod)
+		r.Post("/pods/{namespace}/bulk", handler.BulkPodAction)
 		r.Get("/pods/{namespace}/{name}/containers", handler.GetContainers)
+		r.Post("/pods/{namespace}/{name}/debug", handler.CreateDebugContainer)
 		r.Get("/pods/{namespace}/{name}/logs", handler.GetPodLogs)
 		r.Get("/pods/{namespace}/{name}/logs/download", handler.DownloadPodLogs)
+		r.Get("/pods/{namespace}/{name}/logs/search", handler.SearchPodLogs)
+		r.Get("/logs/bundle/{namespace}", handler.DownloadLogBundle)
+		r.Get("/logs/archive", handler.GetArchivedLogs)
 		r.Get("/nodes", handler.GetNodes)
+		r.Post("/nodes/{name}/cordon", handler.CordonNode)
+		r.Post("/nodes/{name}/uncordon", handler.UncordonNode)
+		r.Post("/nodes/{name}/drain", handler.DrainNode)
 		r.Get("/metrics/nodes", handler.GetNodeMetrics)
 		r.Get("/metrics/pods", handler.GetPodMetrics)
+		r.Get("/metrics/history", handler.GetMetricsHistory)
+		r.Get("/metrics/io", handler.GetPodIO)
+		r.Get("/recommendations", handler.GetRecommendations)
 		r.Get("/summary", handler.GetClusterSummary)
 		r.Get("/contexts", handler.GetContexts)
 		r.Post("/contexts", handler.SwitchContext)
 		r.Get("/deployments", handler.GetDeployments)
 		r.Get("/deployments/{namespace}/{name}", handler.GetDeployment)
+		r.Get("/deployments/{namespace}/{name}/revisions", handler.GetDeploymentRevisions)
+		r.Post("/deployments/{namespace}/{name}/rollback", handler.RollbackDeployment)
+		r.Get("/cronjobs", handler.GetCronJobs)
+		r.Post("/cronjobs/{namespace}/{name}/suspend", handler.SuspendCronJob)
+		r.Post("/cronjobs/{namespace}/{name}/resume", handler.ResumeCronJob)
+		r.Post("/cronjobs/{namespace}/{name}/trigger", handler.TriggerCronJob)
 		r.Get("/services", handler.GetServices)
 		r.Get("/services/{namespace}/{name}", handler.GetService)
 		r.Get("/services/{namespace}/{name}/endpoints", handler.GetServiceEndpoints)
 		r.Get("/configmaps", handler.GetConfigMaps)
 		r.Get("/configmaps/{namespace}/{name}", handler.GetConfigMap)
+		r.Put("/configmaps/{namespace}/{name}", handler.UpdateConfigMap)
+		r.Get("/configmaps/{namespace}/{name}/history", handler.GetConfigMapHistory)
 		r.Get("/events/{namespace}/{kind}/{name}", handler.GetResourceEvents)
+		r.Get("/raw/{group}/{version}/{kind}/{namespace}/{name}", handler.GetRawObject)
+		r.Get("/export/{namespace}", handler.ExportNamespace)
+		r.Patch("/metadata/{group}/{version}/{kind}/{namespace}", handler.BulkPatchMetadata)
+		r.Patch("/metadata/{group}/{version}/{kind}/{namespace}/{name}", handler.PatchMetadata)
+		r.Get("/metadata/{group}/{version}/{kind}/{namespace}/{name}/history", handler.GetEditHistory)
+		r.Post("/metadata/{group}/{version}/{kind}/{namespace}/{name}/undo/{id}", handler.UndoMetadataEdit)
+		r.Post("/apply/dry-run", handler.DryRunApply)
+		r.Post("/apply", handler.ApplyManifest)
+		r.Get("/exec/sessions", execHub.GetSessions)
+		r.Get("/portforwards", portForwards.GetPortForwards)
+		r.Post("/portforwards", portForwards.CreatePortForward)
+		r.Delete("/portforwards/{id}", portForwards.DeletePortForward)
 	})
 
+	// Prometheus metrics of kub and the cluster summary
+	telemetry.Registry.MustRegister(telemetry.NewClusterCollector(func(ctx context.Context) (*models.ClusterSummary, error) {
+		return k8sClient.GetClusterSummary(ctx, "")
+	}))
+	r.Handle("/metrics", telemetry.Handler())
+
 	// WebSocket
 	r.Get("/ws", hub.HandleWebSocket)
 	r.Get("/ws/logs", logStreamHub.HandleLogStream)
+	r.Get("/ws/logs/aggregate", logStreamHub.HandleAggregateLogStream)
+	r.Get("/ws/exec", execHub.HandleExec)
 
 	// Static files (embedded frontend)
 	staticFS, err := fs.Sub(staticFiles, "static")
@@ -113,6 +209,16 @@ func main() {
 	// Start metrics watcher (every 5 seconds)
 	go hub.StartMetricsWatcher(ctx, "", 5*time.Second)
 
+	// Start log archive collector
+	if len(archiveConfig.Targets) > 0 {
+		go logarchive.NewCollector(k8sClient, logArchive, archiveConfig.Targets).Run(ctx)
+	}
+
+	// Start log alert rules from KUB_ALERT_RULES
+	if alertEngine != nil {
+		go alertEngine.Run(ctx)
+	}
+
 	// Start server
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -131,6 +237,7 @@ func main() {
 		<-sigChan
 
 		log.Println("Shutting down server...")
+		portForwards.StopAll()
 		cancel()
 
 		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -0,0 +1,233 @@
+package alerts
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krzyzao/kub/internal/k8s"
+	"github.com/krzyzao/kub/internal/logs"
+	"github.com/krzyzao/kub/internal/models"
+)
+
+const (
+	// Containers tailed at once per namespace and selector
+	engineMaxStreams = 200
+	// How often state of quiet rule/pod pairs is dropped
+	cleanupInterval = time.Minute
+	// Wait before tailing again after a pod watch failed
+	retryInterval = 30 * time.Second
+)
+
+// Notifier delivers an alert; it must not block
+type Notifier func(models.LogAlert)
+
+// target is a set of pods tailed once for every rule that selects it
+type target struct {
+	namespace string
+	selector  string
+}
+
+// ruleState tracks the matches of one rule in one pod
+type ruleState struct {
+	rule       *compiledRule
+	matches    []time.Time // within the window, oldest first
+	lastFired  time.Time
+	suppressed int
+}
+
+// Engine tails the pods selected by the rules and evaluates every line
+type Engine struct {
+	client    *k8s.Client
+	targets   map[target][]*compiledRule
+	notifiers []Notifier
+
+	mu     sync.Mutex
+	states map[string]*ruleState // rule/namespace/pod
+}
+
+// NewEngine validates the rules and creates an engine delivering alerts to
+// the notifiers
+func NewEngine(client *k8s.Client, rules []Rule, notifiers ...Notifier) (*Engine, error) {
+	e := &Engine{
+		client:    client,
+		targets:   make(map[target][]*compiledRule),
+		notifiers: notifiers,
+		states:    make(map[string]*ruleState),
+	}
+
+	names := make(map[string]bool)
+	for _, rule := range rules {
+		c, err := compileRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate alert rule name %s", rule.Name)
+		}
+		names[rule.Name] = true
+		t := target{namespace: c.Namespace, selector: c.selector}
+		e.targets[t] = append(e.targets[t], c)
+	}
+
+	return e, nil
+}
+
+// Run evaluates the rules until ctx is cancelled. Only lines logged after
+// the engine started are evaluated.
+func (e *Engine) Run(ctx context.Context) {
+	started := time.Now()
+
+	var wg sync.WaitGroup
+	for t, rules := range e.targets {
+		wg.Add(1)
+		go func(t target, rules []*compiledRule) {
+			defer wg.Done()
+			e.runTarget(ctx, t, rules, started)
+		}(t, rules)
+	}
+
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case now := <-ticker.C:
+			e.cleanup(now)
+		}
+	}
+}
+
+func (e *Engine) runTarget(ctx context.Context, t target, rules []*compiledRule, started time.Time) {
+	log.Printf("Evaluating %d log alert rule(s) for %s (selector %q)", len(rules), t.namespace, t.selector)
+
+	for {
+		lines := make(chan k8s.LogLine, 256)
+		tailer := e.client.NewLogTailer(t.namespace, k8s.LogTailOptions{
+			LabelSelector: t.selector,
+			TailLines:     1, // history is skipped by timestamp below
+			Timestamps:    true,
+			MaxStreams:    engineMaxStreams,
+		}, lines, nil)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- tailer.Run(ctx)
+			close(lines)
+		}()
+
+		for line := range lines {
+			ts, text := logs.SplitTimestamp(line.Text)
+			if ts == nil || ts.Before(started) {
+				continue
+			}
+			for _, rule := range rules {
+				if rule.Container != "" && rule.Container != line.Container {
+					continue
+				}
+				if rule.regex.MatchString(text) {
+					e.match(rule, t.namespace, line.Pod, line.Container, *ts, text)
+				}
+			}
+		}
+
+		if err := <-done; err != nil {
+			log.Printf("Log alert tailer for %s: %v", t.namespace, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// match records a match and raises an alert once the threshold is reached
+// within the window, unless the rule fired for the pod within its cooldown
+func (e *Engine) match(rule *compiledRule, namespace, pod, container string, ts time.Time, text string) {
+	key := strings.Join([]string{rule.Name, namespace, pod}, "/")
+
+	e.mu.Lock()
+	st := e.states[key]
+	if st == nil {
+		st = &ruleState{rule: rule}
+		e.states[key] = st
+	}
+
+	st.matches = append(dropBefore(st.matches, ts.Add(-rule.window)), ts)
+
+	if !st.lastFired.IsZero() && ts.Sub(st.lastFired) < rule.cooldown {
+		st.suppressed++
+		e.mu.Unlock()
+		return
+	}
+	if len(st.matches) < rule.threshold {
+		e.mu.Unlock()
+		return
+	}
+
+	alert := models.LogAlert{
+		ID:         newAlertID(),
+		Rule:       rule.Name,
+		Namespace:  namespace,
+		Pod:        pod,
+		Container:  container,
+		Pattern:    rule.Pattern,
+		Matches:    len(st.matches),
+		Window:     rule.window.String(),
+		FirstMatch: st.matches[0],
+		LastMatch:  ts,
+		Line:       text,
+		Suppressed: st.suppressed,
+	}
+	alert.Text = fmt.Sprintf("[%s] %d match(es) of %q in %s/%s (%s) within %s: %s",
+		rule.Name, alert.Matches, rule.Pattern, namespace, pod, container, alert.Window, text)
+
+	st.lastFired = ts
+	st.matches = nil
+	st.suppressed = 0
+	e.mu.Unlock()
+
+	log.Printf("Log alert %s", alert.Text)
+	for _, notify := range e.notifiers {
+		notify(alert)
+	}
+}
+
+// cleanup forgets rule/pod pairs with no recent matches and no active
+// cooldown, e.g. of deleted pods
+func (e *Engine) cleanup(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for key, st := range e.states {
+		st.matches = dropBefore(st.matches, now.Add(-st.rule.window))
+		if len(st.matches) == 0 && now.Sub(st.lastFired) >= st.rule.cooldown {
+			delete(e.states, key)
+		}
+	}
+}
+
+// dropBefore removes the times before cutoff from a sorted slice
+func dropBefore(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(cutoff) {
+		i++
+	}
+	return times[i:]
+}
+
+func newAlertID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,117 @@
+// Package alerts watches container logs for regex rules and raises an alert
+// when a rule matches often enough within its window.
+package alerts
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// Defaults for rules that leave the fields out
+const (
+	DefaultThreshold = 1
+	DefaultWindow    = 5 * time.Minute
+	DefaultCooldown  = 15 * time.Minute
+)
+
+// Rule is a log pattern rule as written in the rules file
+type Rule struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Selector  string `json:"selector,omitempty"`  // empty = every pod of the namespace
+	Container string `json:"container,omitempty"` // empty = every container
+	Pattern   string `json:"pattern"`             // regular expression
+	Threshold int    `json:"threshold,omitempty"` // matches within window that raise an alert
+	Window    string `json:"window,omitempty"`    // Go duration, e.g. 5m
+	Cooldown  string `json:"cooldown,omitempty"`  // quiet period per rule and pod after an alert
+}
+
+// RulesFile is the layout of the file named by KUB_ALERT_RULES
+type RulesFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+// compiledRule is a validated rule
+type compiledRule struct {
+	Rule
+	regex     *regexp.Regexp
+	selector  string // normalized
+	window    time.Duration
+	cooldown  time.Duration
+	threshold int
+}
+
+// LoadRules reads and validates a YAML or JSON rules file
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alert rules: %w", err)
+	}
+
+	var file RulesFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse alert rules: %w", err)
+	}
+
+	for _, rule := range file.Rules {
+		if _, err := compileRule(rule); err != nil {
+			return nil, err
+		}
+	}
+	return file.Rules, nil
+}
+
+func compileRule(rule Rule) (*compiledRule, error) {
+	if rule.Name == "" {
+		return nil, fmt.Errorf("alert rule without a name")
+	}
+	if rule.Namespace == "" {
+		return nil, fmt.Errorf("alert rule %s: namespace is required", rule.Name)
+	}
+
+	c := &compiledRule{
+		Rule:      rule,
+		threshold: rule.Threshold,
+		window:    DefaultWindow,
+		cooldown:  DefaultCooldown,
+	}
+
+	if rule.Pattern == "" {
+		return nil, fmt.Errorf("alert rule %s: pattern is required", rule.Name)
+	}
+	regex, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("alert rule %s: invalid pattern: %w", rule.Name, err)
+	}
+	c.regex = regex
+
+	selector, err := labels.Parse(rule.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("alert rule %s: invalid selector: %w", rule.Name, err)
+	}
+	c.selector = selector.String()
+
+	if c.threshold == 0 {
+		c.threshold = DefaultThreshold
+	}
+	if c.threshold < 0 {
+		return nil, fmt.Errorf("alert rule %s: threshold must be positive", rule.Name)
+	}
+	if rule.Window != "" {
+		if c.window, err = time.ParseDuration(rule.Window); err != nil || c.window <= 0 {
+			return nil, fmt.Errorf("alert rule %s: invalid window %q", rule.Name, rule.Window)
+		}
+	}
+	if rule.Cooldown != "" {
+		if c.cooldown, err = time.ParseDuration(rule.Cooldown); err != nil || c.cooldown < 0 {
+			return nil, fmt.Errorf("alert rule %s: invalid cooldown %q", rule.Name, rule.Cooldown)
+		}
+	}
+
+	return c, nil
+}
@@ -0,0 +1,63 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/krzyzao/kub/internal/models"
+)
+
+// Time allowed for one webhook delivery
+const webhookTimeout = 10 * time.Second
+
+// NewWebhook returns a notifier that POSTs each alert as JSON to url. The
+// payload has a "text" field, so chat incoming webhooks (e.g. Slack) accept
+// it as is. Deliveries run in the background and failures are logged.
+func NewWebhook(webhookURL string) (Notifier, error) {
+	parsed, err := url.Parse(webhookURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid alert webhook URL %q", webhookURL)
+	}
+
+	client := &http.Client{Timeout: webhookTimeout}
+	return func(alert models.LogAlert) {
+		go func() {
+			if err := postAlert(client, webhookURL, alert); err != nil {
+				log.Printf("Failed to deliver alert %s to webhook: %v", alert.ID, err)
+			}
+		}()
+	}, nil
+}
+
+func postAlert(client *http.Client, webhookURL string, alert models.LogAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
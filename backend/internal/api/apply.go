@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/krzyzao/kub/internal/k8s"
+	"github.com/krzyzao/kub/internal/models"
+)
+
+// maxManifestSize limits the size of a manifest accepted for apply
+const maxManifestSize = 5 << 20
+
+type applyRequest struct {
+	Manifest          string `json:"manifest"`
+	Namespace         string `json:"namespace"`
+	Force             bool   `json:"force"`
+	ConfirmationToken string `json:"confirmationToken"`
+}
+
+// DryRunApply server-side applies a manifest with dryRun=All and returns the
+// per-document diff. When every document passes, a confirmation token for
+// ApplyManifest is included.
+func (h *Handler) DryRunApply(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeApplyRequest(w, r)
+	if !ok {
+		return
+	}
+
+	results, err := h.k8sClient.ApplyManifest(r.Context(), []byte(req.Manifest), k8s.ApplyOptions{
+		DefaultNamespace: req.Namespace,
+		DryRun:           true,
+		Force:            req.Force,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	plan := models.ApplyPlan{DryRun: true, Results: results}
+	if !hasApplyErrors(results) {
+		token, expires := h.confirmations.issue(applyDigest(req))
+		plan.Token = token
+		plan.ExpiresAt = &expires
+	}
+
+	respondJSON(w, plan)
+}
+
+// ApplyManifest applies a manifest previously validated by DryRunApply. The
+// confirmation token only matches the exact manifest and options of that dry run.
+func (h *Handler) ApplyManifest(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeApplyRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if !h.confirmations.consume(req.ConfirmationToken, applyDigest(req)) {
+		http.Error(w, "a valid confirmationToken from /api/apply/dry-run is required", http.StatusPreconditionRequired)
+		return
+	}
+
+	results, err := h.k8sClient.ApplyManifest(r.Context(), []byte(req.Manifest), k8s.ApplyOptions{
+		DefaultNamespace: req.Namespace,
+		Force:            req.Force,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status := http.StatusOK
+	if hasApplyErrors(results) {
+		status = http.StatusUnprocessableEntity
+	}
+	respondJSONStatus(w, status, models.ApplyPlan{Results: results})
+}
+
+// decodeApplyRequest reads either a JSON request or a raw YAML body (with
+// namespace, force and confirm passed as query parameters)
+func decodeApplyRequest(w http.ResponseWriter, r *http.Request) (applyRequest, bool) {
+	var req applyRequest
+	body := http.MaxBytesReader(w, r.Body, maxManifestSize)
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return req, false
+		}
+	} else {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return req, false
+		}
+		query := r.URL.Query()
+		req.Manifest = string(data)
+		req.Namespace = query.Get("namespace")
+		req.Force = query.Get("force") == "true"
+		req.ConfirmationToken = query.Get("confirm")
+	}
+
+	if strings.TrimSpace(req.Manifest) == "" {
+		http.Error(w, "manifest is required", http.StatusBadRequest)
+		return req, false
+	}
+	if req.Namespace != "" && !validateK8sName(req.Namespace) {
+		http.Error(w, "invalid namespace", http.StatusBadRequest)
+		return req, false
+	}
+
+	return req, true
+}
+
+func applyDigest(req applyRequest) string {
+	options := "namespace=" + req.Namespace + " force=" + strconv.FormatBool(req.Force)
+	return actionDigest("apply", options, []string{req.Manifest})
+}
+
+func hasApplyErrors(results []models.ApplyResult) bool {
+	for _, res := range results {
+		if res.Error != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/krzyzao/kub/internal/k8s"
+	"github.com/krzyzao/kub/internal/models"
+)
+
+// configMapKeyRegex validates ConfigMap data keys
+var configMapKeyRegex = regexp.MustCompile(`^[-._a-zA-Z0-9]{1,253}$`)
+
+// UpdateConfigMap changes ConfigMap data keys. The request must carry the
+// resourceVersion it was based on; if the ConfigMap changed since, 409 is
+// returned with a three-way view of the edited keys.
+func (h *Handler) UpdateConfigMap(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	name := chi.URLParam(r, "name")
+
+	if namespace == "" || !validateK8sName(namespace) || name == "" || !validateK8sName(name) {
+		http.Error(w, "invalid namespace or name parameter", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		ResourceVersion  string            `json:"resourceVersion"`
+		Data             map[string]string `json:"data"`   // keys to add or replace
+		Remove           []string          `json:"remove"` // keys to delete
+		Base             map[string]string `json:"base"`   // original values of edited keys, for the conflict view
+		RestartConsumers bool              `json:"restartConsumers"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.ResourceVersion == "" {
+		http.Error(w, "resourceVersion is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Data) == 0 && len(req.Remove) == 0 {
+		http.Error(w, "no changes given", http.StatusBadRequest)
+		return
+	}
+	for k := range req.Data {
+		if !configMapKeyRegex.MatchString(k) {
+			http.Error(w, "invalid key: "+k, http.StatusBadRequest)
+			return
+		}
+	}
+	for _, k := range req.Remove {
+		if !configMapKeyRegex.MatchString(k) {
+			http.Error(w, "invalid key: "+k, http.StatusBadRequest)
+			return
+		}
+	}
+
+	update := k8s.ConfigMapUpdate{
+		ResourceVersion: req.ResourceVersion,
+		Set:             req.Data,
+		Remove:          req.Remove,
+	}
+
+	cm, changes, err := h.k8sClient.UpdateConfigMapData(r.Context(), namespace, name, update)
+	var conflict *k8s.ConfigMapConflictError
+	if errors.As(err, &conflict) {
+		respondJSONStatus(w, http.StatusConflict, k8s.ConfigMapConflictView(req.Base, conflict, update))
+		return
+	}
+	if err != nil {
+		respondError(w, err, http.StatusInternalServerError, "failed to update configmap")
+		return
+	}
+
+	result := models.ConfigMapUpdateResult{ConfigMap: *cm}
+	if len(changes) > 0 {
+		result.Edit = h.recordEdit(r, "ConfigMap", namespace, name, cm.ResourceVersion, changes)
+	}
+
+	if req.RestartConsumers {
+		result.Restarted, result.RestartErrors = h.restartConfigMapConsumers(r, namespace, name)
+	}
+
+	respondJSON(w, result)
+}
+
+// GetConfigMapHistory returns the edits made to a ConfigMap through kub, newest first
+func (h *Handler) GetConfigMapHistory(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	name := chi.URLParam(r, "name")
+
+	if namespace == "" || !validateK8sName(namespace) || name == "" || !validateK8sName(name) {
+		http.Error(w, "invalid namespace or name parameter", http.StatusBadRequest)
+		return
+	}
+
+	_, currentContext := h.k8sClient.GetContexts()
+	records, err := h.history.List(currentContext, "ConfigMap", namespace, name)
+	if err != nil {
+		respondError(w, err, http.StatusInternalServerError, "failed to read history")
+		return
+	}
+
+	respondJSON(w, records)
+}
+
+func (h *Handler) restartConfigMapConsumers(r *http.Request, namespace, name string) ([]string, map[string]string) {
+	consumers, err := h.k8sClient.FindConfigMapConsumers(r.Context(), namespace, name)
+	if err != nil {
+		log.Printf("API error: %v", err)
+		return nil, map[string]string{"*": "failed to find consumers"}
+	}
+
+	var restarted []string
+	failed := make(map[string]string)
+	for _, d := range consumers {
+		if err := h.k8sClient.RestartDeployment(r.Context(), namespace, d); err != nil {
+			log.Printf("API error: %v", err)
+			failed[d] = "failed to restart deployment"
+			continue
+		}
+		restarted = append(restarted, d)
+		h.hub.TrackRollout(namespace, d)
+	}
+
+	if len(failed) == 0 {
+		failed = nil
+	}
+	return restarted, failed
+}
+
+// recordEdit appends an edit to the local history. A failure to write the
+// history is logged but does not fail the request: the change already happened.
+func (h *Handler) recordEdit(r *http.Request, kind, namespace, name, resourceVersion string, changes []models.EditChange) *models.EditRecord {
+	_, currentContext := h.k8sClient.GetContexts()
+	record := models.EditRecord{
+		ID:              newSessionID(),
+		Timestamp:       time.Now(),
+		User:            h.requestUser(r),
+		Context:         currentContext,
+		Kind:            kind,
+		Namespace:       namespace,
+		Name:            name,
+		ResourceVersion: resourceVersion,
+		Changes:         changes,
+	}
+
+	if err := h.history.Append(record); err != nil {
+		log.Printf("Failed to record edit history: %v", err)
+	}
+	return &record
+}
+
+// requestUser identifies who made a request: the user asserted by an
+// authenticating reverse proxy listed in KUB_TRUSTED_PROXIES, or else the
+// client address. Identity headers from anyone else are ignored since any
+// client can set them.
+func (h *Handler) requestUser(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if ip := net.ParseIP(host); ip != nil && h.trustedProxy(ip) {
+		for _, header := range []string{"X-Forwarded-User", "X-Remote-User", "X-Forwarded-Email"} {
+			if user := r.Header.Get(header); user != "" {
+				return user
+			}
+		}
+	}
+	return host
+}
+
+func (h *Handler) trustedProxy(ip net.IP) bool {
+	for _, network := range h.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// trustedProxiesFromEnv parses KUB_TRUSTED_PROXIES, a comma-separated list of
+// IPs and CIDRs of reverse proxies whose user headers are trusted
+func trustedProxiesFromEnv() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(os.Getenv("KUB_TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil {
+				bits := 8 * len(ip.To16())
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 32
+				}
+				networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("Ignoring invalid KUB_TRUSTED_PROXIES entry %q", entry)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
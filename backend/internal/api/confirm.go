@@ -0,0 +1,82 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// confirmationTTL is how long a confirmation token stays valid
+const confirmationTTL = 60 * time.Second
+
+// confirmationStore issues single-use tokens for destructive actions.
+// A token is bound to a digest of the action and its exact targets, so it can
+// only confirm the operation that was shown to the user.
+type confirmationStore struct {
+	mu     sync.Mutex
+	tokens map[string]pendingConfirmation
+}
+
+type pendingConfirmation struct {
+	digest  string
+	expires time.Time
+}
+
+func newConfirmationStore() *confirmationStore {
+	return &confirmationStore{tokens: make(map[string]pendingConfirmation)}
+}
+
+// issue returns a new token for the action digest
+func (s *confirmationStore) issue(digest string) (string, time.Time) {
+	b := make([]byte, 16)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	expires := time.Now().Add(confirmationTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop expired tokens while we hold the lock
+	now := time.Now()
+	for t, p := range s.tokens {
+		if now.After(p.expires) {
+			delete(s.tokens, t)
+		}
+	}
+
+	s.tokens[token] = pendingConfirmation{digest: digest, expires: expires}
+	return token, expires
+}
+
+// consume validates and invalidates a token; it fails if the token is unknown,
+// expired or was issued for a different action digest
+func (s *confirmationStore) consume(token, digest string) bool {
+	if token == "" {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.tokens[token]
+	if !ok {
+		return false
+	}
+	delete(s.tokens, token)
+
+	return p.digest == digest && time.Now().Before(p.expires)
+}
+
+// actionDigest hashes an action, its options and its (order independent) targets
+func actionDigest(action string, options string, targets []string) string {
+	sorted := append([]string(nil), targets...)
+	sort.Strings(sorted)
+
+	h := sha256.New()
+	h.Write([]byte(action + "\x00" + options + "\x00" + strings.Join(sorted, "\x00")))
+	return hex.EncodeToString(h.Sum(nil))
+}
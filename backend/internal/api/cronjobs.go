@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/krzyzao/kub/internal/models"
+)
+
+// GetCronJobs returns all cronjobs
+func (h *Handler) GetCronJobs(w http.ResponseWriter, r *http.Request) {
+	namespace := r.URL.Query().Get("namespace")
+
+	if !validateK8sName(namespace) {
+		http.Error(w, "invalid namespace parameter", http.StatusBadRequest)
+		return
+	}
+
+	cronJobs, err := h.k8sClient.GetCronJobs(r.Context(), namespace)
+	if err != nil {
+		respondError(w, err, http.StatusInternalServerError, "failed to fetch cronjobs")
+		return
+	}
+
+	respondJSON(w, cronJobs)
+}
+
+// SuspendCronJob sets spec.suspend on a cronjob
+func (h *Handler) SuspendCronJob(w http.ResponseWriter, r *http.Request) {
+	h.setCronJobSuspend(w, r, true)
+}
+
+// ResumeCronJob clears spec.suspend on a cronjob
+func (h *Handler) ResumeCronJob(w http.ResponseWriter, r *http.Request) {
+	h.setCronJobSuspend(w, r, false)
+}
+
+func (h *Handler) setCronJobSuspend(w http.ResponseWriter, r *http.Request, suspend bool) {
+	namespace := chi.URLParam(r, "namespace")
+	name := chi.URLParam(r, "name")
+
+	if namespace == "" || !validateK8sName(namespace) || name == "" || !validateK8sName(name) {
+		http.Error(w, "invalid namespace or name parameter", http.StatusBadRequest)
+		return
+	}
+
+	cronJob, err := h.k8sClient.SetCronJobSuspend(r.Context(), namespace, name, suspend)
+	if err != nil {
+		respondError(w, err, http.StatusInternalServerError, "failed to update cronjob")
+		return
+	}
+
+	respondJSON(w, cronJob)
+}
+
+// TriggerCronJob creates a one-off job from a cronjob and returns the log
+// stream endpoint that follows its pods
+func (h *Handler) TriggerCronJob(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	name := chi.URLParam(r, "name")
+
+	if namespace == "" || !validateK8sName(namespace) || name == "" || !validateK8sName(name) {
+		http.Error(w, "invalid namespace or name parameter", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.k8sClient.TriggerCronJob(r.Context(), namespace, name)
+	if err != nil {
+		respondError(w, err, http.StatusInternalServerError, "failed to trigger cronjob")
+		return
+	}
+
+	params := url.Values{}
+	params.Set("namespace", namespace)
+	params.Set("job", job)
+
+	respondJSONStatus(w, http.StatusCreated, models.CronJobTrigger{
+		Namespace: namespace,
+		CronJob:   name,
+		Job:       job,
+		LogsURL:   "/ws/logs?" + params.Encode(),
+	})
+}
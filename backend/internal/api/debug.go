@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/krzyzao/kub/internal/k8s"
+	"github.com/krzyzao/kub/internal/models"
+)
+
+const (
+	// Image used when the request and KUB_DEBUG_IMAGE do not set one
+	defaultDebugImage = "busybox:1.36"
+	// How long to wait for the debug container to start (includes image pull)
+	debugStartTimeout = 2 * time.Minute
+)
+
+// CreateDebugContainer attaches an ephemeral debug container to a pod, waits
+// until it runs and returns the exec and log stream endpoints for it
+func (h *Handler) CreateDebugContainer(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	name := chi.URLParam(r, "name")
+
+	if namespace == "" || !validateK8sName(namespace) || name == "" || !validateK8sName(name) {
+		http.Error(w, "invalid namespace or name parameter", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Image   string   `json:"image"`
+		Target  string   `json:"target"`
+		Command []string `json:"command"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	image := req.Image
+	if image == "" {
+		image = os.Getenv("KUB_DEBUG_IMAGE")
+	}
+	if image == "" {
+		image = defaultDebugImage
+	}
+	if strings.ContainsAny(image, " \t\n") {
+		http.Error(w, "invalid image", http.StatusBadRequest)
+		return
+	}
+	if !validateK8sName(req.Target) {
+		http.Error(w, "invalid target container", http.StatusBadRequest)
+		return
+	}
+
+	container, err := h.k8sClient.CreateDebugContainer(r.Context(), namespace, name, k8s.DebugOptions{
+		Image:           image,
+		TargetContainer: req.Target,
+		Command:         req.Command,
+	})
+	if err != nil {
+		respondError(w, err, http.StatusInternalServerError, "failed to create debug container")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), debugStartTimeout)
+	defer cancel()
+
+	if err := h.k8sClient.WaitForEphemeralContainer(ctx, namespace, name, container); err != nil {
+		respondError(w, err, http.StatusInternalServerError, "debug container failed to start")
+		return
+	}
+
+	params := url.Values{}
+	params.Set("namespace", namespace)
+	params.Set("pod", name)
+	params.Set("container", container)
+
+	respondJSON(w, models.DebugSession{
+		Namespace: namespace,
+		Pod:       name,
+		Container: container,
+		Image:     image,
+		Target:    req.Target,
+		ExecURL:   "/ws/exec?" + params.Encode(),
+		LogsURL:   "/ws/logs?" + params.Encode(),
+	})
+}
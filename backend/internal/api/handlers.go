@@ -4,18 +4,25 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/krzyzao/kub/internal/edithistory"
 	"github.com/krzyzao/kub/internal/k8s"
+	"github.com/krzyzao/kub/internal/logarchive"
 )
 
 // k8sNameRegex validates Kubernetes resource names and namespaces
 var k8sNameRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
 
+// k8sSubdomainRegex validates DNS-1123 subdomains such as node names
+var k8sSubdomainRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
 // validateK8sName validates a Kubernetes name (namespace, pod name, etc.)
 func validateK8sName(name string) bool {
 	if name == "" {
@@ -27,6 +34,18 @@ func validateK8sName(name string) bool {
 	return k8sNameRegex.MatchString(name)
 }
 
+// validateK8sSubdomain validates names that may contain dots, like nodes
+// (ip-10-0-0-1.ec2.internal) and pods
+func validateK8sSubdomain(name string) bool {
+	if name == "" {
+		return true
+	}
+	if len(name) > 253 {
+		return false
+	}
+	return k8sSubdomainRegex.MatchString(name)
+}
+
 // respondError logs the detailed error and returns a generic message
 func respondError(w http.ResponseWriter, err error, statusCode int, userMessage string) {
 	log.Printf("API error: %v", err)
@@ -35,12 +54,26 @@ func respondError(w http.ResponseWriter, err error, statusCode int, userMessage
 
 // Handler holds the HTTP handlers
 type Handler struct {
-	k8sClient *k8s.Client
+	k8sClient     *k8s.Client
+	hub           *Hub
+	confirmations *confirmationStore
+	history       *edithistory.Store
+	archive       *logarchive.Store
+	// Proxies allowed to assert the user in X-Forwarded-User and similar headers
+	trustedProxies []*net.IPNet
 }
 
 // NewHandler creates a new handler
-func NewHandler(k8sClient *k8s.Client) *Handler {
-	return &Handler{k8sClient: k8sClient}
+func NewHandler(k8sClient *k8s.Client, hub *Hub, archive *logarchive.Store) *Handler {
+	return &Handler{
+		k8sClient:     k8sClient,
+		hub:           hub,
+		confirmations: newConfirmationStore(),
+		history:       edithistory.NewStore(filepath.Join(edithistory.DataDir(), "history")),
+		archive:       archive,
+
+		trustedProxies: trustedProxiesFromEnv(),
+	}
 }
 
 // GetNamespaces returns all namespaces
@@ -120,9 +153,9 @@ func (h *Handler) GetPod(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, pod)
 }
 
-// GetNodes returns all nodes with metrics
+// GetNodes returns all nodes with metrics and pod allocation
 func (h *Handler) GetNodes(w http.ResponseWriter, r *http.Request) {
-	nodes, err := h.k8sClient.GetNodes(r.Context())
+	nodes, err := h.k8sClient.GetNodesWithAllocation(r.Context())
 	if err != nil {
 		respondError(w, err, http.StatusInternalServerError, "failed to fetch nodes")
 		return
@@ -155,20 +188,6 @@ func (h *Handler) GetNodes(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Count pods per node and merge with nodes
-	pods, err := h.k8sClient.GetPods(r.Context(), "")
-	if err == nil {
-		podCountMap := make(map[string]int)
-		for _, p := range pods {
-			if p.Node != "" {
-				podCountMap[p.Node]++
-			}
-		}
-		for i := range nodes {
-			nodes[i].PodCount = podCountMap[nodes[i].Name]
-		}
-	}
-
 	respondJSON(w, nodes)
 }
 
@@ -410,6 +429,13 @@ func respondJSON(w http.ResponseWriter, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
+// respondJSONStatus writes a JSON body with a non-200 status code
+func respondJSONStatus(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
 // GetPodLogs handles log retrieval
 func (h *Handler) GetPodLogs(w http.ResponseWriter, r *http.Request) {
 	namespace := chi.URLParam(r, "namespace")
@@ -442,6 +468,13 @@ func (h *Handler) GetPodLogs(w http.ResponseWriter, r *http.Request) {
 	previous := query.Get("previous") == "true"
 	timestamps := query.Get("timestamps") == "true"
 
+	// Optional JSON/logfmt parsing with level and field filters
+	processor, err := logProcessor(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	logOpts := k8s.LogOptions{
 		Container:  container,
 		TailLines:  tailLines,
@@ -450,6 +483,12 @@ func (h *Handler) GetPodLogs(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logs, err := h.k8sClient.GetPodLogs(r.Context(), namespace, name, logOpts)
+	archived := false
+	if err != nil {
+		// The pod may be gone with its logs kept in the local archive
+		logs, err = h.archivedPodLogs(err, namespace, name, container, tailLines, timestamps)
+		archived = err == nil
+	}
 	if err != nil {
 		respondError(w, err, http.StatusInternalServerError, "failed to fetch logs")
 		return
@@ -460,6 +499,13 @@ func (h *Handler) GetPodLogs(w http.ResponseWriter, r *http.Request) {
 		"container": container,
 		"pod":       name,
 		"namespace": namespace,
+		"archived":  archived,
+	}
+
+	if processor != nil {
+		filtered, entries := processLogs(processor, string(logs), timestamps)
+		response["logs"] = filtered
+		response["entries"] = entries
 	}
 
 	respondJSON(w, response)
@@ -524,6 +570,9 @@ func (h *Handler) DownloadPodLogs(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logs, err := h.k8sClient.GetPodLogs(r.Context(), namespace, name, logOpts)
+	if err != nil {
+		logs, err = h.archivedPodLogs(err, namespace, name, container, tailLines, timestamps)
+	}
 	if err != nil {
 		respondError(w, err, http.StatusInternalServerError, "failed to fetch logs")
 		return
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/krzyzao/kub/internal/k8s"
+	"github.com/krzyzao/kub/internal/logarchive"
+	"github.com/krzyzao/kub/internal/logs"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// GetArchivedLogs lists the containers kept in the local log archive for the
+// current context, optionally for one namespace
+func (h *Handler) GetArchivedLogs(w http.ResponseWriter, r *http.Request) {
+	namespace := r.URL.Query().Get("namespace")
+
+	if !validateK8sName(namespace) {
+		http.Error(w, "invalid namespace parameter", http.StatusBadRequest)
+		return
+	}
+
+	_, currentContext := h.k8sClient.GetContexts()
+	respondJSON(w, h.archive.List(currentContext, namespace))
+}
+
+// openArchivedLogs falls back to the log archive when fetching logs failed
+// because the pod no longer exists. It returns the original error if the pod
+// exists or nothing was archived for it.
+func openArchivedLogs(client *k8s.Client, archive *logarchive.Store, fetchErr error, namespace, pod, container string, since *time.Time) (io.ReadCloser, error) {
+	if archive == nil || !apierrors.IsNotFound(fetchErr) {
+		return nil, fetchErr
+	}
+
+	_, currentContext := client.GetContexts()
+	stream, err := archive.Open(currentContext, namespace, pod, container, since)
+	if errors.Is(err, logarchive.ErrNotFound) {
+		return nil, fetchErr
+	}
+	return stream, err
+}
+
+// archivedPodLogs returns the last tailLines lines (0 = all) of the archived
+// log of a pod that no longer exists, without the archive's timestamps unless
+// they were requested
+func (h *Handler) archivedPodLogs(fetchErr error, namespace, pod, container string, tailLines int64, timestamps bool) ([]byte, error) {
+	stream, err := openArchivedLogs(h.k8sClient, h.archive, fetchErr, namespace, pod, container, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !timestamps {
+			_, line = logs.SplitTimestamp(line)
+		}
+		lines = append(lines, line)
+		if tailLines > 0 && int64(len(lines)) > tailLines {
+			lines = lines[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read archived logs: %w", err)
+	}
+
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// copyArchivedLines sends the lines of an archived log, removing the
+// archive's timestamps unless they were requested
+func copyArchivedLines(ctx context.Context, stream io.Reader, timestamps bool, logChan chan<- string) {
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !timestamps {
+			_, line = logs.SplitTimestamp(line)
+		}
+		select {
+		case logChan <- line + "\n":
+		case <-ctx.Done():
+			return
+		}
+	}
+}
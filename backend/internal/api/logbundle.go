@@ -0,0 +1,197 @@
+package api
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/krzyzao/kub/internal/k8s"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// bundleIndex is written last to a bundle and lists what it contains
+type bundleIndex struct {
+	Namespace string    `json:"namespace"`
+	Selector  string    `json:"selector,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	Pods      []string  `json:"pods"`
+	Errors    []string  `json:"errors,omitempty"` // entries that could not be collected
+}
+
+// DownloadLogBundle streams a zip with the current and previous logs of every
+// container (init, regular and ephemeral), the pod details and the pod events
+// for a pod (?pod=), a workload (?kind=Deployment&name=), a label selector
+// (?selector=) or the whole namespace. ?tailLines= limits each log.
+func (h *Handler) DownloadLogBundle(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+
+	if namespace == "" || !validateK8sName(namespace) {
+		http.Error(w, "invalid namespace parameter", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+
+	var tailLines int64
+	if tl := query.Get("tailLines"); tl != "" {
+		parsed, err := strconv.ParseInt(tl, 10, 64)
+		if err != nil || parsed < 0 {
+			http.Error(w, "invalid tailLines parameter", http.StatusBadRequest)
+			return
+		}
+		tailLines = parsed
+	}
+
+	index := bundleIndex{Namespace: namespace, CreatedAt: time.Now()}
+	scope := namespace
+
+	switch {
+	case query.Get("pod") != "":
+		pod := query.Get("pod")
+		if !validateK8sName(pod) {
+			http.Error(w, "invalid pod parameter", http.StatusBadRequest)
+			return
+		}
+		index.Pods = []string{pod}
+		scope = pod
+	case query.Get("kind") != "":
+		name := query.Get("name")
+		if name == "" || !validateK8sName(name) {
+			http.Error(w, "invalid name parameter", http.StatusBadRequest)
+			return
+		}
+		selector, err := h.k8sClient.WorkloadSelector(r.Context(), namespace, query.Get("kind"), name)
+		if err != nil {
+			respondError(w, err, http.StatusBadRequest, "failed to resolve workload")
+			return
+		}
+		index.Selector = selector
+		scope = name
+	case query.Get("selector") != "":
+		selector, err := labels.Parse(query.Get("selector"))
+		if err != nil {
+			http.Error(w, "invalid selector parameter", http.StatusBadRequest)
+			return
+		}
+		index.Selector = selector.String()
+	}
+
+	if index.Pods == nil {
+		pods, err := h.k8sClient.ListPodNames(r.Context(), namespace, index.Selector)
+		if err != nil {
+			respondError(w, err, http.StatusInternalServerError, "failed to list pods")
+			return
+		}
+		index.Pods = pods
+	}
+
+	filename := fmt.Sprintf("%s-logs-%s.zip", scope, index.CreatedAt.Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+	zw := zip.NewWriter(w)
+	for _, pod := range index.Pods {
+		if r.Context().Err() != nil {
+			return
+		}
+		index.Errors = append(index.Errors, h.writePodBundle(r.Context(), zw, namespace, pod, tailLines)...)
+	}
+
+	if err := writeZipJSON(zw, "bundle.json", index); err != nil {
+		log.Printf("API error: %v", err)
+		return
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("API error: %v", err)
+	}
+}
+
+// writePodBundle adds <pod>/pod.json, <pod>/events.json and the container logs
+// of one pod, returning the entries that could not be collected
+func (h *Handler) writePodBundle(ctx context.Context, zw *zip.Writer, namespace, pod string, tailLines int64) []string {
+	var errs []string
+
+	details, err := h.k8sClient.GetPod(ctx, namespace, pod)
+	if err != nil {
+		log.Printf("API error: %v", err)
+		return []string{pod + ": failed to get pod"}
+	}
+	if err := writeZipJSON(zw, pod+"/pod.json", details); err != nil {
+		errs = append(errs, pod+"/pod.json: "+err.Error())
+	}
+
+	events, err := h.k8sClient.GetResourceEvents(ctx, namespace, "Pod", pod)
+	if err == nil {
+		err = writeZipJSON(zw, pod+"/events.json", events)
+	}
+	if err != nil {
+		errs = append(errs, pod+"/events.json: "+err.Error())
+	}
+
+	containers, err := h.k8sClient.GetContainerNames(ctx, namespace, pod)
+	if err != nil {
+		return append(errs, pod+": failed to list containers")
+	}
+
+	for _, container := range containers {
+		for _, previous := range []bool{false, true} {
+			name := pod + "/" + container + ".log"
+			if previous {
+				name = pod + "/" + container + ".previous.log"
+			}
+
+			stream, err := h.k8sClient.OpenPodLogs(ctx, namespace, pod, k8s.LogOptions{
+				Container:  container,
+				TailLines:  tailLines,
+				Previous:   previous,
+				Timestamps: true,
+			})
+			if err != nil {
+				// Most containers never restarted and have no previous log
+				if !previous {
+					errs = append(errs, name+": "+err.Error())
+				}
+				continue
+			}
+
+			if err := writeZipStream(zw, name, stream); err != nil {
+				errs = append(errs, name+": "+err.Error())
+			}
+			stream.Close()
+		}
+	}
+
+	return errs
+}
+
+// writeZipStream copies a stream into a new zip entry without buffering it
+func writeZipStream(zw *zip.Writer, name string, r io.Reader) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to create zip entry: %w", err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("failed to write zip entry: %w", err)
+	}
+	return nil
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to create zip entry: %w", err)
+	}
+	_, err = f.Write(data)
+	return err
+}
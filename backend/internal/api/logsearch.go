@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/krzyzao/kub/internal/k8s"
+	"github.com/krzyzao/kub/internal/logs"
+	"github.com/krzyzao/kub/internal/models"
+)
+
+const (
+	// Match limit when the request does not set one
+	defaultSearchLimit = 500
+	maxSearchLimit     = 10000
+	maxSearchContext   = 50
+	maxSearchPattern   = 1024
+)
+
+// SearchPodLogs scans a container's logs server-side and streams matching
+// lines with context as newline-delimited JSON (one models.LogSearchResult per
+// line, ending with a summary record)
+func (h *Handler) SearchPodLogs(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	name := chi.URLParam(r, "name")
+
+	if namespace == "" || !validateK8sName(namespace) || name == "" || !validateK8sName(name) {
+		http.Error(w, "invalid namespace or name parameter", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+
+	q := logs.Query{
+		Pattern:         query.Get("q"),
+		Regex:           query.Get("regex") == "true",
+		CaseSensitive:   query.Get("caseSensitive") == "true",
+		Limit:           defaultSearchLimit,
+		Timestamped:     true,
+		StripTimestamps: query.Get("timestamps") != "true",
+	}
+	if q.Pattern == "" || len(q.Pattern) > maxSearchPattern {
+		http.Error(w, "q parameter is required (at most 1024 characters)", http.StatusBadRequest)
+		return
+	}
+	if _, err := q.Compile(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	processor, err := logProcessor(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q.Processor = processor
+
+	var ok bool
+	if q.Context, ok = intParam(w, query.Get("context"), 0, maxSearchContext, "context"); !ok {
+		return
+	}
+	if v := query.Get("limit"); v != "" {
+		if q.Limit, ok = intParam(w, v, 1, maxSearchLimit, "limit"); !ok {
+			return
+		}
+	}
+
+	logOpts := k8s.LogOptions{
+		Container:  query.Get("container"),
+		Previous:   query.Get("previous") == "true",
+		Timestamps: true, // needed for until and result timestamps
+	}
+	if v := query.Get("sinceTime"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid sinceTime parameter (RFC3339)", http.StatusBadRequest)
+			return
+		}
+		logOpts.SinceTime = &t
+	}
+	if v := query.Get("sinceSeconds"); v != "" {
+		seconds, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || seconds <= 0 {
+			http.Error(w, "invalid sinceSeconds parameter", http.StatusBadRequest)
+			return
+		}
+		logOpts.SinceSeconds = seconds
+	}
+	if v := query.Get("until"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid until parameter (RFC3339)", http.StatusBadRequest)
+			return
+		}
+		q.Until = &t
+	}
+
+	stream, err := h.k8sClient.OpenPodLogs(r.Context(), namespace, name, logOpts)
+	if err != nil {
+		since := logOpts.SinceTime
+		if since == nil && logOpts.SinceSeconds > 0 {
+			t := time.Now().Add(-time.Duration(logOpts.SinceSeconds) * time.Second)
+			since = &t
+		}
+		stream, err = openArchivedLogs(h.k8sClient, h.archive, err, namespace, name, logOpts.Container, since)
+	}
+	if err != nil {
+		respondError(w, err, http.StatusInternalServerError, "failed to fetch logs")
+		return
+	}
+	defer stream.Close()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	err = logs.Search(stream, q, func(res models.LogSearchResult) error {
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+		// Flush matches as they are found; context lines follow shortly anyway
+		if flusher != nil && res.Type != "context" {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Log search for %s/%s ended: %v", namespace, name, err)
+	}
+}
+
+// intParam parses an optional integer query parameter within [min, max]
+func intParam(w http.ResponseWriter, value string, min, max int, name string) (int, bool) {
+	if value == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		http.Error(w, "invalid "+name+" parameter", http.StatusBadRequest)
+		return 0, false
+	}
+	return n, true
+}
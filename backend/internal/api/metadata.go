@@ -0,0 +1,195 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/krzyzao/kub/internal/k8s"
+	"github.com/krzyzao/kub/internal/models"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// PatchMetadata adds, changes or removes labels and annotations of any object
+// (`{"labels": {"key": "value", "removed": null}, "annotations": {...}}`).
+// Every change is recorded in the edit history and can be undone.
+func (h *Handler) PatchMetadata(w http.ResponseWriter, r *http.Request) {
+	ref, ok := parseObjectRef(w, r, true)
+	if !ok {
+		return
+	}
+
+	var patch k8s.MetadataPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !validMetadataPatch(w, patch) {
+		return
+	}
+
+	result, code := h.patchObjectMetadata(r, ref, patch)
+	respondJSONStatus(w, code, result)
+}
+
+// BulkPatchMetadata applies the same label and annotation changes to every
+// object of a kind matched by a label selector
+func (h *Handler) BulkPatchMetadata(w http.ResponseWriter, r *http.Request) {
+	ref, ok := parseObjectRef(w, r, false)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		LabelSelector string `json:"labelSelector"`
+		k8s.MetadataPatch
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	// An empty selector would match every object of the kind
+	selector, err := labels.Parse(req.LabelSelector)
+	if err != nil || selector.Empty() {
+		http.Error(w, "a non-empty, valid labelSelector is required", http.StatusBadRequest)
+		return
+	}
+	if !validMetadataPatch(w, req.MetadataPatch) {
+		return
+	}
+
+	names, err := h.k8sClient.ListObjectNames(r.Context(), ref.Group, ref.Version, ref.Kind, ref.Namespace, selector.String())
+	switch {
+	case errors.Is(err, k8s.ErrNamespaceRequired):
+		http.Error(w, "kind is namespaced, \"_\" is only for cluster-scoped kinds", http.StatusBadRequest)
+		return
+	case apierrors.IsNotFound(err):
+		respondError(w, err, http.StatusNotFound, "kind not found")
+		return
+	case err != nil:
+		respondError(w, err, http.StatusInternalServerError, "failed to list objects")
+		return
+	}
+
+	results := make([]models.MetadataPatchResult, 0, len(names))
+	for _, name := range names {
+		target := ref
+		target.Name = name
+		result, _ := h.patchObjectMetadata(r, target, req.MetadataPatch)
+		results = append(results, result)
+	}
+
+	respondJSON(w, results)
+}
+
+// UndoMetadataEdit reverts the label and annotation changes of a recorded edit
+func (h *Handler) UndoMetadataEdit(w http.ResponseWriter, r *http.Request) {
+	ref, ok := parseObjectRef(w, r, true)
+	if !ok {
+		return
+	}
+	editID := chi.URLParam(r, "id")
+
+	_, currentContext := h.k8sClient.GetContexts()
+	record, err := h.history.Find(currentContext, k8s.HistoryKind(ref.Group, ref.Kind), ref.Namespace, ref.Name, editID)
+	if err != nil {
+		respondError(w, err, http.StatusInternalServerError, "failed to read history")
+		return
+	}
+	if record == nil {
+		http.Error(w, "edit not found", http.StatusNotFound)
+		return
+	}
+
+	patch := k8s.RevertMetadataPatch(record.Changes)
+	if patch.Empty() {
+		http.Error(w, "edit has no label or annotation changes to undo", http.StatusBadRequest)
+		return
+	}
+
+	result, code := h.patchObjectMetadata(r, ref, patch)
+	respondJSONStatus(w, code, result)
+}
+
+// GetEditHistory returns the edits made to an object through kub, newest first
+func (h *Handler) GetEditHistory(w http.ResponseWriter, r *http.Request) {
+	ref, ok := parseObjectRef(w, r, true)
+	if !ok {
+		return
+	}
+
+	_, currentContext := h.k8sClient.GetContexts()
+	records, err := h.history.List(currentContext, k8s.HistoryKind(ref.Group, ref.Kind), ref.Namespace, ref.Name)
+	if err != nil {
+		respondError(w, err, http.StatusInternalServerError, "failed to read history")
+		return
+	}
+
+	respondJSON(w, records)
+}
+
+// patchObjectMetadata patches one object and returns the result with the
+// HTTP status it maps to
+func (h *Handler) patchObjectMetadata(r *http.Request, ref objectRef, patch k8s.MetadataPatch) (models.MetadataPatchResult, int) {
+	result := models.MetadataPatchResult{Namespace: ref.Namespace, Name: ref.Name}
+
+	resourceVersion, changes, err := h.k8sClient.PatchMetadata(r.Context(), ref.Group, ref.Version, ref.Kind, ref.Namespace, ref.Name, patch)
+	switch {
+	case errors.Is(err, k8s.ErrNamespaceRequired):
+		result.Status = "invalid"
+		result.Message = "kind is namespaced, \"_\" is only for cluster-scoped kinds"
+		return result, http.StatusBadRequest
+	case apierrors.IsNotFound(err):
+		result.Status = "notFound"
+		result.Message = ref.Kind + " not found"
+		return result, http.StatusNotFound
+	case apierrors.IsInvalid(err):
+		// The API server's message names the rejected label or annotation
+		result.Status = "invalid"
+		result.Message = apiErrorMessage(err)
+		return result, http.StatusUnprocessableEntity
+	case apierrors.IsConflict(err):
+		result.Status = "conflict"
+		result.Message = ref.Kind + " was changed concurrently, retry"
+		return result, http.StatusConflict
+	case err != nil:
+		log.Printf("API error: %v", err)
+		result.Status = "failed"
+		result.Message = "failed to patch " + ref.Kind
+		return result, http.StatusInternalServerError
+	}
+
+	if len(changes) == 0 {
+		result.Status = "unchanged"
+		return result, http.StatusOK
+	}
+
+	result.Status = "patched"
+	result.Edit = h.recordEdit(r, k8s.HistoryKind(ref.Group, ref.Kind), ref.Namespace, ref.Name, resourceVersion, changes)
+	return result, http.StatusOK
+}
+
+// apiErrorMessage returns the message of an API server status error
+func apiErrorMessage(err error) string {
+	var status apierrors.APIStatus
+	if errors.As(err, &status) {
+		return status.Status().Message
+	}
+	return err.Error()
+}
+
+func validMetadataPatch(w http.ResponseWriter, patch k8s.MetadataPatch) bool {
+	if patch.Empty() {
+		http.Error(w, "no label or annotation changes given", http.StatusBadRequest)
+		return false
+	}
+	if err := k8s.ValidateMetadataPatch(patch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/krzyzao/kub/internal/history"
+	"github.com/krzyzao/kub/internal/k8s"
+	"github.com/krzyzao/kub/internal/models"
+)
+
+const (
+	// History sent to new /ws clients
+	initialHistoryRange = time.Hour
+	initialHistoryStep  = time.Minute
+	// Range of a history query without range or from
+	defaultHistoryRange = time.Hour
+	// Points per series asked of a metrics provider without a step; the
+	// step is at least minProviderStep. Prometheus refuses more than
+	// maxProviderPoints points per series.
+	defaultProviderPoints = 240
+	minProviderStep       = 15 * time.Second
+	maxProviderPoints     = 11000
+)
+
+// GetMetricsHistory returns CPU and memory time series of nodes, pods and
+// namespaces. The time range is either range (a duration back from now) or
+// from/to (RFC3339); step averages points into coarser intervals. When the
+// metrics provider keeps history (Prometheus) it is queried instead of the
+// local store.
+func (h *Handler) GetMetricsHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := history.Query{
+		Kind:      query.Get("kind"),
+		Namespace: query.Get("namespace"),
+		Name:      query.Get("name"),
+		To:        time.Now(),
+	}
+
+	switch q.Kind {
+	case "", history.KindNode, history.KindPod, history.KindNamespace:
+	default:
+		http.Error(w, "invalid kind parameter (node, pod or namespace)", http.StatusBadRequest)
+		return
+	}
+	if !validateK8sName(q.Namespace) || !validateK8sSubdomain(q.Name) {
+		http.Error(w, "invalid namespace or name parameter", http.StatusBadRequest)
+		return
+	}
+
+	if v := query.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid to parameter (RFC3339)", http.StatusBadRequest)
+			return
+		}
+		q.To = t
+	}
+
+	q.From = q.To.Add(-defaultHistoryRange)
+	if v := query.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid from parameter (RFC3339)", http.StatusBadRequest)
+			return
+		}
+		q.From = t
+	} else if v := query.Get("range"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "invalid range parameter", http.StatusBadRequest)
+			return
+		}
+		q.From = q.To.Add(-d)
+	}
+	if !q.From.Before(q.To) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	if v := query.Get("step"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "invalid step parameter", http.StatusBadRequest)
+			return
+		}
+		q.Step = d
+	}
+
+	result, err := h.queryHistory(r.Context(), q)
+	if err != nil {
+		respondError(w, err, http.StatusInternalServerError, "failed to fetch metrics history")
+		return
+	}
+
+	respondJSON(w, result)
+}
+
+// queryHistory answers a history query from the metrics provider if it keeps
+// history, else from the local store
+func (h *Handler) queryHistory(ctx context.Context, q history.Query) (models.MetricsHistory, error) {
+	provider, ok := h.k8sClient.Metrics.(k8s.HistoryProvider)
+	if !ok {
+		return h.hub.metricsHistory.Query(q), nil
+	}
+
+	span := q.To.Sub(q.From)
+	if q.Step == 0 {
+		q.Step = span / defaultProviderPoints
+	}
+	if q.Step < minProviderStep {
+		q.Step = minProviderStep
+	}
+	if floor := span / maxProviderPoints; q.Step < floor {
+		q.Step = floor
+	}
+
+	series, err := provider.UsageRange(ctx, k8s.UsageRangeQuery{
+		Kind:      q.Kind,
+		Namespace: q.Namespace,
+		Name:      q.Name,
+		From:      q.From,
+		To:        q.To,
+		Step:      q.Step,
+		Max:       q.Max,
+	})
+	if err != nil {
+		return models.MetricsHistory{}, err
+	}
+
+	return models.MetricsHistory{
+		From:   q.From.UnixMilli(),
+		To:     q.To.UnixMilli(),
+		Step:   q.Step.Milliseconds(),
+		Series: series,
+	}, nil
+}
+
+// GetPodIO returns the network and disk throughput of pods. Only metrics
+// providers that collect I/O (Prometheus) support it.
+func (h *Handler) GetPodIO(w http.ResponseWriter, r *http.Request) {
+	namespace := r.URL.Query().Get("namespace")
+	pod := r.URL.Query().Get("pod")
+	if !validateK8sName(namespace) || !validateK8sSubdomain(pod) {
+		http.Error(w, "invalid namespace or pod parameter", http.StatusBadRequest)
+		return
+	}
+
+	provider, ok := h.k8sClient.Metrics.(k8s.IOProvider)
+	if !ok {
+		http.Error(w, "network and disk I/O need the Prometheus metrics provider (KUB_PROMETHEUS_URL)", http.StatusNotImplemented)
+		return
+	}
+
+	usage, err := provider.PodIO(r.Context(), namespace, pod)
+	if err != nil {
+		respondError(w, err, http.StatusInternalServerError, "failed to fetch pod I/O metrics")
+		return
+	}
+
+	respondJSON(w, usage)
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/krzyzao/kub/internal/telemetry"
 	"golang.org/x/time/rate"
 )
 
@@ -55,6 +56,7 @@ func (rl *RateLimiter) RateLimitMiddleware(next http.Handler) http.Handler {
 		ip := getClientIP(r)
 
 		if !rl.getLimiter(ip).Allow() {
+			telemetry.RateLimitRejections.Inc()
 			w.Header().Set("Retry-After", "1")
 			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
 			return
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/krzyzao/kub/internal/k8s"
+	"github.com/krzyzao/kub/internal/models"
+)
+
+// defaultDrainTimeout applies when the drain request does not set one
+const defaultDrainTimeout = 5 * time.Minute
+
+// CordonNode marks a node as unschedulable
+func (h *Handler) CordonNode(w http.ResponseWriter, r *http.Request) {
+	h.setNodeCordon(w, r, true)
+}
+
+// UncordonNode marks a node as schedulable again
+func (h *Handler) UncordonNode(w http.ResponseWriter, r *http.Request) {
+	h.setNodeCordon(w, r, false)
+}
+
+func (h *Handler) setNodeCordon(w http.ResponseWriter, r *http.Request, cordon bool) {
+	name := chi.URLParam(r, "name")
+	if name == "" || !validateK8sSubdomain(name) {
+		http.Error(w, "invalid node name", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.k8sClient.CordonNode(r.Context(), name, cordon); err != nil {
+		respondError(w, err, http.StatusInternalServerError, "failed to update node")
+		return
+	}
+
+	respondJSON(w, map[string]interface{}{"status": "ok", "node": name, "unschedulable": cordon})
+}
+
+// DrainNode starts draining a node after confirmation. Progress, per-pod
+// evictions and blockers are streamed over the /ws hub as "drain" messages.
+func (h *Handler) DrainNode(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if name == "" || !validateK8sSubdomain(name) {
+		http.Error(w, "invalid node name", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		TimeoutSeconds     int64  `json:"timeoutSeconds"`
+		GracePeriod        *int64 `json:"gracePeriod"`
+		DeleteEmptyDirData bool   `json:"deleteEmptyDirData"`
+		Force              bool   `json:"force"`
+		ConfirmationToken  string `json:"confirmationToken"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.TimeoutSeconds < 0 || (req.GracePeriod != nil && *req.GracePeriod < 0) {
+		http.Error(w, "invalid timeoutSeconds or gracePeriod", http.StatusBadRequest)
+		return
+	}
+
+	timeout := defaultDrainTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	}
+
+	options := podActionOptions(req.GracePeriod, req.Force) +
+		" timeout=" + timeout.String() +
+		" deleteEmptyDirData=" + strconv.FormatBool(req.DeleteEmptyDirData)
+	if !h.confirmAction(w, "drain", options, []string{"node/" + name}, req.ConfirmationToken) {
+		return
+	}
+
+	id, ok := h.hub.StartDrain(name, k8s.DrainOptions{
+		Timeout:            timeout,
+		GracePeriodSeconds: req.GracePeriod,
+		DeleteEmptyDirData: req.DeleteEmptyDirData,
+		Force:              req.Force,
+	})
+	if !ok {
+		http.Error(w, "a drain of this node is already running", http.StatusConflict)
+		return
+	}
+
+	respondJSONStatus(w, http.StatusAccepted, map[string]string{"status": "started", "id": id, "node": name})
+}
+
+// StartDrain runs a node drain in the background, broadcasting every step.
+// It returns false if the node is already being drained.
+func (h *Hub) StartDrain(node string, opts k8s.DrainOptions) (string, bool) {
+	h.drainsMu.Lock()
+	if h.drains[node] {
+		h.drainsMu.Unlock()
+		return "", false
+	}
+	h.drains[node] = true
+	h.drainsMu.Unlock()
+
+	id := newSessionID()
+	emit := func(e models.DrainEvent) {
+		e.ID = id
+		e.Node = node
+		e.Timestamp = time.Now().UnixMilli()
+		h.publish("drain", e)
+	}
+
+	go func() {
+		defer func() {
+			h.drainsMu.Lock()
+			delete(h.drains, node)
+			h.drainsMu.Unlock()
+		}()
+
+		emit(models.DrainEvent{Phase: "started"})
+
+		// Drains end with the hub
+		if err := h.k8sClient.DrainNode(h.runContext(), node, opts, emit); err != nil {
+			emit(models.DrainEvent{Phase: "failed", Message: err.Error()})
+			return
+		}
+		emit(models.DrainEvent{Phase: "completed", Message: "node drained"})
+	}()
+
+	return id, true
+}
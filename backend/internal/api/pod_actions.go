@@ -0,0 +1,221 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/krzyzao/kub/internal/k8s"
+	"github.com/krzyzao/kub/internal/models"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// DeletePod deletes a pod. The first request returns 428 with a confirmation
+// token; repeating it with ?confirm=<token> performs the deletion.
+func (h *Handler) DeletePod(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	name := chi.URLParam(r, "name")
+
+	if namespace == "" || !validateK8sName(namespace) || name == "" || !validateK8sName(name) {
+		http.Error(w, "invalid namespace or name parameter", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+
+	var gracePeriod *int64
+	if gp := query.Get("gracePeriod"); gp != "" {
+		parsed, err := strconv.ParseInt(gp, 10, 64)
+		if err != nil || parsed < 0 {
+			http.Error(w, "invalid gracePeriod parameter", http.StatusBadRequest)
+			return
+		}
+		gracePeriod = &parsed
+	}
+	force := query.Get("force") == "true"
+
+	targets := []string{namespace + "/" + name}
+	if !h.confirmAction(w, "delete", podActionOptions(gracePeriod, force), targets, query.Get("confirm")) {
+		return
+	}
+
+	result := h.runPodAction("delete", namespace, name, gracePeriod, force, r)
+	respondPodActionResult(w, result)
+}
+
+// EvictPod evicts a pod through the Eviction API, using the same two-step
+// confirmation flow as DeletePod
+func (h *Handler) EvictPod(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	name := chi.URLParam(r, "name")
+
+	if namespace == "" || !validateK8sName(namespace) || name == "" || !validateK8sName(name) {
+		http.Error(w, "invalid namespace or name parameter", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		GracePeriod       *int64 `json:"gracePeriod"`
+		ConfirmationToken string `json:"confirmationToken"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.GracePeriod != nil && *req.GracePeriod < 0 {
+		http.Error(w, "invalid gracePeriod", http.StatusBadRequest)
+		return
+	}
+
+	targets := []string{namespace + "/" + name}
+	if !h.confirmAction(w, "evict", podActionOptions(req.GracePeriod, false), targets, req.ConfirmationToken) {
+		return
+	}
+
+	result := h.runPodAction("evict", namespace, name, req.GracePeriod, false, r)
+	respondPodActionResult(w, result)
+}
+
+// BulkPodAction deletes or evicts every pod in a namespace matching a label selector.
+// The confirmation token covers the exact set of pods shown in the first response.
+func (h *Handler) BulkPodAction(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+
+	if namespace == "" || !validateK8sName(namespace) {
+		http.Error(w, "invalid namespace parameter", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Action            string `json:"action"` // delete, evict
+		LabelSelector     string `json:"labelSelector"`
+		GracePeriod       *int64 `json:"gracePeriod"`
+		Force             bool   `json:"force"`
+		ConfirmationToken string `json:"confirmationToken"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Action != "delete" && req.Action != "evict" {
+		http.Error(w, "action must be delete or evict", http.StatusBadRequest)
+		return
+	}
+	if req.GracePeriod != nil && *req.GracePeriod < 0 {
+		http.Error(w, "invalid gracePeriod", http.StatusBadRequest)
+		return
+	}
+	// An empty selector would match every pod in the namespace
+	selector, err := labels.Parse(req.LabelSelector)
+	if err != nil || selector.Empty() {
+		http.Error(w, "a non-empty, valid labelSelector is required", http.StatusBadRequest)
+		return
+	}
+	if req.Action == "evict" {
+		req.Force = false
+	}
+
+	names, err := h.k8sClient.ListPodNames(r.Context(), namespace, selector.String())
+	if err != nil {
+		respondError(w, err, http.StatusInternalServerError, "failed to list pods")
+		return
+	}
+
+	targets := make([]string, 0, len(names))
+	for _, name := range names {
+		targets = append(targets, namespace+"/"+name)
+	}
+
+	options := podActionOptions(req.GracePeriod, req.Force) + " selector=" + selector.String()
+	if !h.confirmAction(w, req.Action, options, targets, req.ConfirmationToken) {
+		return
+	}
+
+	results := make([]models.PodActionResult, 0, len(names))
+	for _, name := range names {
+		results = append(results, h.runPodAction(req.Action, namespace, name, req.GracePeriod, req.Force, r))
+	}
+
+	respondJSON(w, results)
+}
+
+// confirmAction checks the confirmation token for an action. If it is missing or
+// invalid a new token is issued, a 428 response is written and false is returned.
+func (h *Handler) confirmAction(w http.ResponseWriter, action, options string, targets []string, token string) bool {
+	digest := actionDigest(action, options, targets)
+	if h.confirmations.consume(token, digest) {
+		return true
+	}
+
+	newToken, expires := h.confirmations.issue(digest)
+	respondJSONStatus(w, http.StatusPreconditionRequired, models.ConfirmationRequired{
+		Action:    action,
+		Targets:   targets,
+		Token:     newToken,
+		ExpiresAt: expires,
+	})
+	return false
+}
+
+func (h *Handler) runPodAction(action, namespace, name string, gracePeriod *int64, force bool, r *http.Request) models.PodActionResult {
+	result := models.PodActionResult{Namespace: namespace, Name: name, Action: action}
+
+	var err error
+	if action == "evict" {
+		err = h.k8sClient.EvictPod(r.Context(), namespace, name, gracePeriod)
+	} else {
+		err = h.k8sClient.DeletePod(r.Context(), namespace, name, k8s.PodDeleteOptions{
+			GracePeriodSeconds: gracePeriod,
+			Force:              force,
+		})
+	}
+
+	var blocked *k8s.EvictionBlockedError
+	switch {
+	case err == nil && action == "evict":
+		result.Status = "evicted"
+	case err == nil:
+		result.Status = "deleted"
+	case errors.As(err, &blocked):
+		result.Status = "blocked"
+		result.Message = blocked.Reason
+	case apierrors.IsNotFound(err):
+		result.Status = "notFound"
+		result.Message = "pod not found"
+	default:
+		log.Printf("API error: %v", err)
+		result.Status = "failed"
+		result.Message = fmt.Sprintf("failed to %s pod", action)
+	}
+
+	return result
+}
+
+func respondPodActionResult(w http.ResponseWriter, result models.PodActionResult) {
+	switch result.Status {
+	case "blocked":
+		respondJSONStatus(w, http.StatusTooManyRequests, result)
+	case "notFound":
+		respondJSONStatus(w, http.StatusNotFound, result)
+	case "failed":
+		respondJSONStatus(w, http.StatusInternalServerError, result)
+	default:
+		respondJSON(w, result)
+	}
+}
+
+func podActionOptions(gracePeriod *int64, force bool) string {
+	gp := "default"
+	if gracePeriod != nil {
+		gp = strconv.FormatInt(*gracePeriod, 10)
+	}
+	return fmt.Sprintf("gracePeriod=%s force=%t", gp, force)
+}
@@ -0,0 +1,296 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/krzyzao/kub/internal/k8s"
+	"github.com/krzyzao/kub/internal/models"
+)
+
+const (
+	// How often an active forward checks that its pod is still running
+	portForwardCheckInterval = 5 * time.Second
+	// Upper bound for the reconnect backoff
+	maxPortForwardBackoff = 30 * time.Second
+)
+
+// PortForwardManager starts, supervises and stops port-forwards to pods and services
+type PortForwardManager struct {
+	k8sClient *k8s.Client
+	hub       *Hub
+
+	mu       sync.Mutex
+	forwards map[string]*managedForward
+}
+
+type managedForward struct {
+	info   models.PortForward
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewPortForwardManager creates a new port-forward manager
+func NewPortForwardManager(k8sClient *k8s.Client, hub *Hub) *PortForwardManager {
+	return &PortForwardManager{
+		k8sClient: k8sClient,
+		hub:       hub,
+		forwards:  make(map[string]*managedForward),
+	}
+}
+
+// CreatePortForward starts a new port-forward
+func (m *PortForwardManager) CreatePortForward(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Namespace string `json:"namespace"`
+		Kind      string `json:"kind"` // pod or service
+		Name      string `json:"name"`
+		Port      int    `json:"port"`      // pod port or service port
+		LocalPort int    `json:"localPort"` // 0 = random free port
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Namespace == "" || !validateK8sName(req.Namespace) || req.Name == "" || !validateK8sName(req.Name) {
+		http.Error(w, "invalid namespace or name", http.StatusBadRequest)
+		return
+	}
+	if req.Kind != "pod" && req.Kind != "service" {
+		http.Error(w, "kind must be pod or service", http.StatusBadRequest)
+		return
+	}
+	if req.Port < 0 || req.Port > 65535 || (req.Kind == "pod" && req.Port == 0) {
+		http.Error(w, "invalid port", http.StatusBadRequest)
+		return
+	}
+	if req.LocalPort < 0 || req.LocalPort > 65535 {
+		http.Error(w, "invalid localPort", http.StatusBadRequest)
+		return
+	}
+
+	info := models.PortForward{
+		ID:         newSessionID(),
+		Namespace:  req.Namespace,
+		Kind:       req.Kind,
+		Name:       req.Name,
+		LocalPort:  req.LocalPort,
+		RemotePort: req.Port,
+		Status:     "Starting",
+		StartedAt:  time.Now(),
+	}
+
+	// Establish the first connection synchronously so bind and resolve errors
+	// are reported to the caller instead of only over the hub
+	ctx, cancel := context.WithCancel(context.Background())
+	session, err := m.connect(r.Context(), &info, nil)
+	if err != nil {
+		cancel()
+		respondError(w, err, http.StatusBadRequest, "failed to start port forward")
+		return
+	}
+
+	fwd := &managedForward{info: info, cancel: cancel, done: make(chan struct{})}
+
+	m.mu.Lock()
+	m.forwards[info.ID] = fwd
+	m.mu.Unlock()
+
+	m.publish(info)
+	go m.supervise(ctx, fwd, session)
+
+	respondJSON(w, info)
+}
+
+// GetPortForwards lists all managed port-forwards
+func (m *PortForwardManager) GetPortForwards(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	forwards := make([]models.PortForward, 0, len(m.forwards))
+	for _, fwd := range m.forwards {
+		forwards = append(forwards, fwd.info)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(forwards, func(i, j int) bool {
+		return forwards[i].StartedAt.Before(forwards[j].StartedAt)
+	})
+
+	respondJSON(w, forwards)
+}
+
+// DeletePortForward stops a port-forward
+func (m *PortForwardManager) DeletePortForward(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	m.mu.Lock()
+	fwd, ok := m.forwards[id]
+	m.mu.Unlock()
+
+	if !ok {
+		http.Error(w, "port forward not found", http.StatusNotFound)
+		return
+	}
+
+	fwd.cancel()
+	<-fwd.done
+
+	respondJSON(w, map[string]string{"status": "ok", "id": id})
+}
+
+// StopAll stops every port-forward, used on server shutdown
+func (m *PortForwardManager) StopAll() {
+	m.mu.Lock()
+	forwards := make([]*managedForward, 0, len(m.forwards))
+	for _, fwd := range m.forwards {
+		forwards = append(forwards, fwd)
+	}
+	m.mu.Unlock()
+
+	for _, fwd := range forwards {
+		fwd.cancel()
+		<-fwd.done
+	}
+}
+
+// supervise keeps the forward alive, reconnecting to a replacement pod when
+// the current one goes away, until the forward is stopped
+func (m *PortForwardManager) supervise(ctx context.Context, fwd *managedForward, session *k8s.PortForwardSession) {
+	defer close(fwd.done)
+
+	m.mu.Lock()
+	info := fwd.info
+	m.mu.Unlock()
+
+	podLabels, _ := m.k8sClient.GetPodLabels(ctx, info.Namespace, info.Pod)
+	backoff := time.Second
+
+	for {
+		if session != nil {
+			err := m.watchSession(ctx, info, session)
+			if ctx.Err() != nil {
+				break
+			}
+			info.Status = "Reconnecting"
+			info.Restarts++
+			if err != nil {
+				info.Error = err.Error()
+			}
+			m.update(fwd, info)
+			backoff = time.Second
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		var err error
+		session, err = m.connect(ctx, &info, podLabels)
+		if err != nil {
+			info.Status = "Reconnecting"
+			info.Error = err.Error()
+			m.update(fwd, info)
+			backoff *= 2
+			if backoff > maxPortForwardBackoff {
+				backoff = maxPortForwardBackoff
+			}
+			continue
+		}
+		if labels, err := m.k8sClient.GetPodLabels(ctx, info.Namespace, info.Pod); err == nil {
+			podLabels = labels
+		}
+		m.update(fwd, info)
+	}
+
+	if session != nil {
+		session.Stop()
+	}
+
+	info.Status = "Stopped"
+	info.Error = ""
+	m.mu.Lock()
+	delete(m.forwards, info.ID)
+	m.mu.Unlock()
+	m.publish(info)
+
+	log.Printf("Port forward %s to %s %s/%s stopped", info.ID, info.Kind, info.Namespace, info.Name)
+}
+
+// connect resolves the backing pod and starts forwarding to it, updating info
+func (m *PortForwardManager) connect(ctx context.Context, info *models.PortForward, podLabels map[string]string) (*k8s.PortForwardSession, error) {
+	var pod string
+	var targetPort int
+	var err error
+
+	switch {
+	case info.Kind == "service":
+		pod, targetPort, err = m.k8sClient.ResolveServicePod(ctx, info.Namespace, info.Name, info.RemotePort)
+	case info.Pod == "":
+		pod, targetPort = info.Name, info.RemotePort
+	default:
+		pod, err = m.k8sClient.FindReplacementPod(ctx, info.Namespace, info.Pod, podLabels)
+		targetPort = info.RemotePort
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := m.k8sClient.StartPortForward(ctx, info.Namespace, pod, info.LocalPort, targetPort)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the same local port across reconnects
+	info.LocalPort = session.LocalPort
+	info.Pod = pod
+	info.TargetPort = targetPort
+	info.Status = "Active"
+	info.Error = ""
+
+	log.Printf("Port forward %s: localhost:%d -> %s/%s:%d", info.ID, info.LocalPort, info.Namespace, pod, targetPort)
+	return session, nil
+}
+
+// watchSession blocks until the session ends or its pod stops running
+func (m *PortForwardManager) watchSession(ctx context.Context, info models.PortForward, session *k8s.PortForwardSession) error {
+	defer session.Stop()
+
+	ticker := time.NewTicker(portForwardCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-session.Done:
+			return err
+		case <-ticker.C:
+			running, err := m.k8sClient.IsPodRunning(ctx, info.Namespace, info.Pod)
+			if err == nil && !running {
+				return nil
+			}
+		}
+	}
+}
+
+func (m *PortForwardManager) update(fwd *managedForward, info models.PortForward) {
+	m.mu.Lock()
+	fwd.info = info
+	m.mu.Unlock()
+	m.publish(info)
+}
+
+func (m *PortForwardManager) publish(info models.PortForward) {
+	m.hub.publish("portforward", info)
+}
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/krzyzao/kub/internal/k8s"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// clusterScoped is the namespace URL placeholder for cluster-scoped objects
+const clusterScoped = "_"
+
+var (
+	apiGroupRegex   = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+	apiVersionRegex = regexp.MustCompile(`^v[0-9]+((alpha|beta)[0-9]+)?$`)
+	kindRegex       = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+)
+
+// objectRef identifies an object of any kind from URL parameters
+type objectRef struct {
+	Group     string // "core" for the legacy API group
+	Version   string
+	Kind      string
+	Namespace string // empty for cluster-scoped kinds
+	Name      string
+}
+
+// parseObjectRef reads and validates the {group}/{version}/{kind}/{namespace}
+// and, if requireName is set, {name} URL parameters
+func parseObjectRef(w http.ResponseWriter, r *http.Request, requireName bool) (objectRef, bool) {
+	ref := objectRef{
+		Group:     chi.URLParam(r, "group"),
+		Version:   chi.URLParam(r, "version"),
+		Kind:      chi.URLParam(r, "kind"),
+		Namespace: chi.URLParam(r, "namespace"),
+		Name:      chi.URLParam(r, "name"),
+	}
+
+	if !apiGroupRegex.MatchString(ref.Group) || !apiVersionRegex.MatchString(ref.Version) || !kindRegex.MatchString(ref.Kind) {
+		http.Error(w, "invalid group, version or kind parameter", http.StatusBadRequest)
+		return ref, false
+	}
+	if ref.Namespace == clusterScoped {
+		ref.Namespace = ""
+	}
+	if !validateK8sName(ref.Namespace) || (requireName && ref.Name == "") || !validateObjectName(ref.Name) {
+		http.Error(w, "invalid namespace or name parameter", http.StatusBadRequest)
+		return ref, false
+	}
+
+	return ref, true
+}
+
+// validateObjectName accepts any name usable as an API path segment. Names
+// of other kinds are looser than DNS labels: kube-root-ca.crt,
+// foo.example.com (CRDs), system:controller:* (ClusterRoles) or v1.apps
+// (APIServices).
+func validateObjectName(name string) bool {
+	if name == "" {
+		return true
+	}
+	return len(name) <= 253 && name != "." && name != ".." && !strings.ContainsAny(name, "/%")
+}
+
+// GetRawObject returns the full manifest of any object as YAML (default) or
+// JSON (?format=json). ?clean=true strips managedFields, status and other
+// server-populated fields. Use "core" as group for the legacy API group and
+// "_" as namespace for cluster-scoped kinds. Secret values are redacted
+// unless ?includeSecrets=true.
+func (h *Handler) GetRawObject(w http.ResponseWriter, r *http.Request) {
+	ref, ok := parseObjectRef(w, r, true)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format != "" && format != "yaml" && format != "json" {
+		http.Error(w, "format must be yaml or json", http.StatusBadRequest)
+		return
+	}
+
+	obj, err := h.k8sClient.GetRawObject(r.Context(), ref.Group, ref.Version, ref.Kind, ref.Namespace, ref.Name, query.Get("clean") == "true")
+	switch {
+	case errors.Is(err, k8s.ErrNamespaceRequired):
+		http.Error(w, "kind is namespaced, \"_\" is only for cluster-scoped kinds", http.StatusBadRequest)
+		return
+	case apierrors.IsNotFound(err):
+		respondError(w, err, http.StatusNotFound, "object not found")
+		return
+	case err != nil:
+		respondError(w, err, http.StatusInternalServerError, "failed to get object")
+		return
+	}
+
+	// Same opt-in as the namespace export
+	if isSecret(obj) && query.Get("includeSecrets") != "true" {
+		redactSecret(obj)
+	}
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(obj.Object)
+		return
+	}
+
+	data, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		respondError(w, err, http.StatusInternalServerError, "failed to encode object")
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(data)
+}
+
+// redactedValue replaces Secret values not explicitly asked for
+const redactedValue = "REDACTED"
+
+func isSecret(obj *unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+	return gvk.Group == "" && gvk.Kind == "Secret"
+}
+
+// redactSecret replaces the values of a Secret's data and stringData, keeping
+// the keys
+func redactSecret(obj *unstructured.Unstructured) {
+	for _, field := range []string{"data", "stringData"} {
+		values, ok := obj.Object[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for key := range values {
+			values[key] = redactedValue
+		}
+	}
+	// The last-applied annotation holds a full copy of the data
+	if annotations := obj.GetAnnotations(); annotations != nil {
+		if _, ok := annotations["kubectl.kubernetes.io/last-applied-configuration"]; ok {
+			annotations["kubectl.kubernetes.io/last-applied-configuration"] = redactedValue
+			obj.SetAnnotations(annotations)
+		}
+	}
+}
+
+// ExportNamespace exports every object of a namespace as a multi-document YAML
+// file (default) or a tar archive with one file per object (?format=tar).
+// ?clean=true produces re-appliable manifests; secrets are only included with
+// ?includeSecrets=true.
+func (h *Handler) ExportNamespace(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+
+	if namespace == "" || !validateK8sName(namespace) {
+		http.Error(w, "invalid namespace parameter", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format != "" && format != "yaml" && format != "tar" {
+		http.Error(w, "format must be yaml or tar", http.StatusBadRequest)
+		return
+	}
+
+	objects, err := h.k8sClient.ExportNamespace(r.Context(), namespace, k8s.ExportOptions{
+		Clean:          query.Get("clean") == "true",
+		IncludeSecrets: query.Get("includeSecrets") == "true",
+	})
+	if err != nil {
+		respondError(w, err, http.StatusInternalServerError, "failed to export namespace")
+		return
+	}
+
+	timestamp := time.Now().Format("20060102-150405")
+
+	if format == "tar" {
+		filename := fmt.Sprintf("%s-%s.tar", namespace, timestamp)
+		w.Header().Set("Content-Type", "application/x-tar")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+		if err := writeExportTar(w, namespace, objects); err != nil {
+			log.Printf("API error: %v", err)
+		}
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.yaml", namespace, timestamp)
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	for i, obj := range objects {
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			log.Printf("API error: %v", err)
+			return
+		}
+		if i > 0 {
+			w.Write([]byte("---\n"))
+		}
+		w.Write(data)
+	}
+}
+
+// writeExportTar writes one <namespace>/<kind>/<name>.yaml entry per object
+func writeExportTar(w http.ResponseWriter, namespace string, objects []*unstructured.Unstructured) error {
+	tw := tar.NewWriter(w)
+	now := time.Now()
+
+	for _, obj := range objects {
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+
+		// Kinds from different groups can share a name (e.g. Event)
+		kind := strings.ToLower(obj.GetKind())
+		if group := obj.GroupVersionKind().Group; group != "" {
+			kind += "." + group
+		}
+
+		hdr := &tar.Header{
+			Name:    fmt.Sprintf("%s/%s/%s.yaml", namespace, kind, obj.GetName()),
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write tar header: %w", err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("failed to write tar entry: %w", err)
+		}
+	}
+
+	return tw.Close()
+}
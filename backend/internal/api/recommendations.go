@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/krzyzao/kub/internal/history"
+	"github.com/krzyzao/kub/internal/rightsizing"
+)
+
+// Usage history considered by recommendations without a range parameter
+const defaultRecommendationRange = 24 * time.Hour
+
+// GetRecommendations suggests requests and limits per workload from the pod
+// usage history of the last range (default 24h), flagging over- and
+// under-provisioned workloads with the capacity that right-sizing frees
+func (h *Handler) GetRecommendations(w http.ResponseWriter, r *http.Request) {
+	namespace := r.URL.Query().Get("namespace")
+	if !validateK8sName(namespace) {
+		http.Error(w, "invalid namespace parameter", http.StatusBadRequest)
+		return
+	}
+	if namespace == "all" {
+		namespace = ""
+	}
+
+	span := defaultRecommendationRange
+	if v := r.URL.Query().Get("range"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "invalid range parameter", http.StatusBadRequest)
+			return
+		}
+		span = d
+	}
+
+	pods, err := h.k8sClient.GetPods(r.Context(), namespace)
+	if err != nil {
+		respondError(w, err, http.StatusInternalServerError, "failed to fetch pods")
+		return
+	}
+
+	to := time.Now()
+	usage, err := h.queryHistory(r.Context(), history.Query{
+		Kind:      history.KindPod,
+		Namespace: namespace,
+		From:      to.Add(-span),
+		To:        to,
+		Max:       true,
+	})
+	if err != nil {
+		respondError(w, err, http.StatusInternalServerError, "failed to fetch metrics history")
+		return
+	}
+
+	result := rightsizing.Recommend(pods, usage.Series)
+	result.From = usage.From
+	result.To = usage.To
+	result.Step = usage.Step
+	respondJSON(w, result)
+}
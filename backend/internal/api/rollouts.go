@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/krzyzao/kub/internal/k8s"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// rolloutPollInterval is how often a tracked rollout is re-read from the API server
+const rolloutPollInterval = 2 * time.Second
+
+// GetDeploymentRevisions returns the rollout history of a deployment
+func (h *Handler) GetDeploymentRevisions(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	name := chi.URLParam(r, "name")
+
+	if !validateK8sName(namespace) || !validateK8sName(name) {
+		http.Error(w, "invalid namespace or name parameter", http.StatusBadRequest)
+		return
+	}
+
+	revisions, err := h.k8sClient.GetDeploymentRevisions(r.Context(), namespace, name)
+	if err != nil {
+		respondError(w, err, http.StatusInternalServerError, "failed to fetch deployment revisions")
+		return
+	}
+
+	respondJSON(w, revisions)
+}
+
+// RollbackDeployment rolls a deployment back to a previous revision.
+// With dryRun set only the pod template diff is returned; otherwise the rollout
+// progress is reported over the /ws hub as "rollout" messages.
+func (h *Handler) RollbackDeployment(w http.ResponseWriter, r *http.Request) {
+	namespace := chi.URLParam(r, "namespace")
+	name := chi.URLParam(r, "name")
+
+	if namespace == "" || !validateK8sName(namespace) || name == "" || !validateK8sName(name) {
+		http.Error(w, "invalid namespace or name parameter", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Revision int64 `json:"revision"` // 0 = previous revision
+		DryRun   bool  `json:"dryRun"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Revision < 0 {
+		http.Error(w, "invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.k8sClient.RollbackDeployment(r.Context(), namespace, name, req.Revision, req.DryRun)
+	switch {
+	case errors.Is(err, k8s.ErrRevisionNotFound):
+		respondError(w, err, http.StatusNotFound, "revision not found")
+		return
+	case errors.Is(err, k8s.ErrDeploymentPaused):
+		respondError(w, err, http.StatusConflict, "deployment is paused, resume it before rolling back")
+		return
+	case errors.Is(err, k8s.ErrDeploymentChanged):
+		respondError(w, err, http.StatusConflict, "deployment was changed concurrently, retry the rollback")
+		return
+	case apierrors.IsNotFound(err):
+		respondError(w, err, http.StatusNotFound, "deployment not found")
+		return
+	case apierrors.IsInvalid(err):
+		respondError(w, err, http.StatusUnprocessableEntity, "rollback rejected by the API server")
+		return
+	case err != nil:
+		respondError(w, err, http.StatusInternalServerError, "failed to roll back deployment")
+		return
+	}
+
+	if !result.DryRun && result.Changed {
+		h.hub.TrackRollout(namespace, name)
+	}
+
+	respondJSON(w, result)
+}
+
+// TrackRollout broadcasts the progress of a deployment rollout until it
+// completes or fails, including when the controller reports that it exceeded
+// its progressDeadlineSeconds. Tracking the same deployment again replaces
+// the previous tracker.
+func (h *Hub) TrackRollout(namespace, name string) {
+	key := namespace + "/" + name
+
+	h.rolloutsMu.Lock()
+	if cancel, ok := h.rollouts[key]; ok {
+		cancel()
+	}
+	// Trackers end with the hub
+	ctx, cancel := context.WithCancel(h.runContextLocked())
+	h.rollouts[key] = cancel
+	h.rolloutsMu.Unlock()
+
+	go func() {
+		defer func() {
+			h.rolloutsMu.Lock()
+			// Only remove our own entry, a newer tracker may have replaced it
+			if ctx.Err() == nil {
+				delete(h.rollouts, key)
+			}
+			h.rolloutsMu.Unlock()
+			cancel()
+		}()
+
+		h.trackRollout(ctx, namespace, name)
+	}()
+}
+
+func (h *Hub) trackRollout(ctx context.Context, namespace, name string) {
+	ticker := time.NewTicker(rolloutPollInterval)
+	defer ticker.Stop()
+
+	for {
+		reqCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		status, err := h.k8sClient.GetRolloutStatus(reqCtx, namespace, name)
+		cancel()
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Failed to get rollout status for %s/%s: %v", namespace, name, err)
+		} else {
+			// A stuck rollout is Failed through the controller's Progressing
+			// condition (ProgressDeadlineExceeded), as in kubectl
+			h.publish("rollout", status)
+
+			if status.Phase == "Complete" || status.Phase == "Failed" {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
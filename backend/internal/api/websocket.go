@@ -6,13 +6,17 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/krzyzao/kub/internal/edithistory"
+	"github.com/krzyzao/kub/internal/history"
 	"github.com/krzyzao/kub/internal/k8s"
 	"github.com/krzyzao/kub/internal/models"
+	"github.com/krzyzao/kub/internal/telemetry"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/watch"
 )
@@ -59,21 +63,58 @@ type Hub struct {
 	register   chan *websocket.Conn
 	unregister chan *websocket.Conn
 	mu         sync.RWMutex
+
+	rolloutsMu sync.Mutex
+	rollouts   map[string]context.CancelFunc // active rollout trackers by namespace/name
+	runCtx     context.Context               // context of Run, parent of trackers and drains
+
+	drainsMu sync.Mutex
+	drains   map[string]bool // nodes with a drain in progress
+
+	// Container requests and limits kept from the pod watch, so pod metrics
+	// don't list every pod each tick
+	resourcesMu        sync.Mutex
+	resources          k8s.ContainerResources // nil until the watch has started
+	resourcesCluster   string                 // cluster key of the watch
+	resourcesNamespace string                 // namespace of the watch; "" = all
+
+	metricsHistory *history.Store
 }
 
 // NewHub creates a new WebSocket hub
 func NewHub(k8sClient *k8s.Client) *Hub {
-	return &Hub{
+	h := &Hub{
 		k8sClient:  k8sClient,
 		clients:    make(map[*websocket.Conn]bool),
 		broadcast:  make(chan []byte, 256),
 		register:   make(chan *websocket.Conn),
 		unregister: make(chan *websocket.Conn),
+		rollouts:   make(map[string]context.CancelFunc),
+		drains:     make(map[string]bool),
+
+		metricsHistory: newMetricsHistory(),
+	}
+	telemetry.SetBroadcastQueue(func() int { return len(h.broadcast) })
+	return h
+}
+
+// newMetricsHistory creates the metrics history store, persisted below the
+// data directory unless disabled
+func newMetricsHistory() *history.Store {
+	cfg := history.ConfigFromEnv()
+	store := history.NewStore(cfg)
+	if cfg.DiskRetention > 0 {
+		store.SetDisk(history.NewDisk(filepath.Join(edithistory.DataDir(), "metrics"), cfg.DiskRetention))
 	}
+	return store
 }
 
 // Run starts the hub
 func (h *Hub) Run(ctx context.Context) {
+	h.rolloutsMu.Lock()
+	h.runCtx = ctx
+	h.rolloutsMu.Unlock()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -81,6 +122,7 @@ func (h *Hub) Run(ctx context.Context) {
 		case conn := <-h.register:
 			h.mu.Lock()
 			h.clients[conn] = true
+			telemetry.WebSocketClients.WithLabelValues("ws").Set(float64(len(h.clients)))
 			h.mu.Unlock()
 			log.Printf("Client connected. Total clients: %d", len(h.clients))
 		case conn := <-h.unregister:
@@ -89,6 +131,7 @@ func (h *Hub) Run(ctx context.Context) {
 				delete(h.clients, conn)
 				conn.Close()
 			}
+			telemetry.WebSocketClients.WithLabelValues("ws").Set(float64(len(h.clients)))
 			h.mu.Unlock()
 			log.Printf("Client disconnected. Total clients: %d", len(h.clients))
 		case message := <-h.broadcast:
@@ -111,25 +154,47 @@ func (h *Hub) Run(ctx context.Context) {
 						conn.Close()
 					}
 				}
+				telemetry.WebSocketClients.WithLabelValues("ws").Set(float64(len(h.clients)))
 				h.mu.Unlock()
 			}
 		}
 	}
 }
 
+// runContext returns the context of Run, which background work started by
+// requests (rollout trackers, drains) should end with
+func (h *Hub) runContext() context.Context {
+	h.rolloutsMu.Lock()
+	defer h.rolloutsMu.Unlock()
+	return h.runContextLocked()
+}
+
+// runContextLocked is runContext with h.rolloutsMu held
+func (h *Hub) runContextLocked() context.Context {
+	if h.runCtx == nil {
+		return context.Background()
+	}
+	return h.runCtx
+}
+
 // StartPodWatcher starts watching pods and broadcasting changes
 func (h *Hub) StartPodWatcher(ctx context.Context, namespace string) {
-	for {
+	for started := false; ; started = true {
 		select {
 		case <-ctx.Done():
 			return
 		default:
+			if started {
+				telemetry.WatchRestarts.WithLabelValues("pods").Inc()
+			}
+			cluster := h.k8sClient.ClusterKey()
 			watcher, err := h.k8sClient.WatchPods(ctx, namespace)
 			if err != nil {
 				log.Printf("Failed to start pod watcher: %v", err)
 				time.Sleep(5 * time.Second)
 				continue
 			}
+			h.resetResources(cluster, namespace)
 
 			h.handlePodWatch(ctx, watcher)
 		}
@@ -153,6 +218,7 @@ func (h *Hub) handlePodWatch(ctx context.Context, watcher watch.Interface) {
 			if !ok {
 				continue
 			}
+			h.updateResources(event.Type, pod)
 
 			podEvent := models.PodEvent{
 				Type:      string(event.Type),
@@ -174,6 +240,64 @@ func (h *Hub) handlePodWatch(ctx context.Context, watcher watch.Interface) {
 	}
 }
 
+// resetResources starts keeping container resources for a new pod watch.
+// A restarted watch of the same cluster sends every pod again, so the
+// entries are kept to avoid a tick without them.
+func (h *Hub) resetResources(cluster, namespace string) {
+	h.resourcesMu.Lock()
+	defer h.resourcesMu.Unlock()
+	if h.resources == nil || h.resourcesCluster != cluster || h.resourcesNamespace != namespace {
+		h.resources = make(k8s.ContainerResources)
+	}
+	h.resourcesCluster = cluster
+	h.resourcesNamespace = namespace
+}
+
+func (h *Hub) updateResources(eventType watch.EventType, pod *corev1.Pod) {
+	h.resourcesMu.Lock()
+	defer h.resourcesMu.Unlock()
+	if h.resources == nil {
+		return
+	}
+	switch eventType {
+	case watch.Added, watch.Modified:
+		h.resources.SetPod(pod)
+	case watch.Deleted:
+		h.resources.DeletePod(pod.Namespace, pod.Name)
+	}
+}
+
+// watchedResources returns a copy of the container resources of the pod
+// watch, or nil when the watch doesn't cover namespace of the current
+// cluster (not started yet, narrower namespace or a switched context)
+func (h *Hub) watchedResources(namespace string) k8s.ContainerResources {
+	if namespace == "all" {
+		namespace = ""
+	}
+	cluster := h.k8sClient.ClusterKey()
+
+	h.resourcesMu.Lock()
+	defer h.resourcesMu.Unlock()
+	if h.resources == nil || h.resourcesCluster != cluster ||
+		(h.resourcesNamespace != "" && h.resourcesNamespace != namespace) {
+		return nil
+	}
+	resources := make(k8s.ContainerResources, len(h.resources))
+	for key, containers := range h.resources {
+		resources[key] = containers
+	}
+	return resources
+}
+
+// podMetrics gets pod metrics with the container resources of the pod
+// watch, listing pods only when the watch doesn't cover namespace
+func (h *Hub) podMetrics(ctx context.Context, namespace string) ([]models.PodMetrics, error) {
+	if resources := h.watchedResources(namespace); resources != nil {
+		return h.k8sClient.GetPodMetricsWithResources(ctx, namespace, resources)
+	}
+	return h.k8sClient.GetPodMetrics(ctx, namespace)
+}
+
 // StartMetricsWatcher periodically fetches and broadcasts metrics
 func (h *Hub) StartMetricsWatcher(ctx context.Context, namespace string, interval time.Duration) {
 	ticker := time.NewTicker(interval)
@@ -184,19 +308,20 @@ func (h *Hub) StartMetricsWatcher(ctx context.Context, namespace string, interva
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			h.broadcastMetrics(ctx, namespace)
+			snapshot := h.broadcastMetrics(ctx, namespace)
+			h.metricsHistory.Record(h.k8sClient.ClusterKey(), time.UnixMilli(snapshot.Timestamp), snapshot.NodeMetrics, snapshot.PodMetrics)
 		}
 	}
 }
 
-func (h *Hub) broadcastMetrics(ctx context.Context, namespace string) {
+func (h *Hub) broadcastMetrics(ctx context.Context, namespace string) models.MetricsSnapshot {
 	nodeMetrics, err := h.k8sClient.GetNodeMetrics(ctx)
 	if err != nil {
 		log.Printf("Failed to get node metrics: %v", err)
 		nodeMetrics = []models.NodeMetrics{}
 	}
 
-	podMetrics, err := h.k8sClient.GetPodMetrics(ctx, namespace)
+	podMetrics, err := h.podMetrics(ctx, namespace)
 	if err != nil {
 		log.Printf("Failed to get pod metrics: %v", err)
 		podMetrics = []models.PodMetrics{}
@@ -214,10 +339,30 @@ func (h *Hub) broadcastMetrics(ctx context.Context, namespace string) {
 	})
 	if err != nil {
 		log.Printf("Failed to marshal metrics: %v", err)
-		return
+		return snapshot
 	}
 
 	h.broadcast <- data
+	return snapshot
+}
+
+// publish marshals a typed message and queues it for all connected clients
+func (h *Hub) publish(msgType string, data interface{}) {
+	message, err := json.Marshal(map[string]interface{}{
+		"type": msgType,
+		"data": data,
+	})
+	if err != nil {
+		log.Printf("Failed to marshal %s message: %v", msgType, err)
+		return
+	}
+
+	h.broadcast <- message
+}
+
+// PublishAlert broadcasts a log alert to every connected client
+func (h *Hub) PublishAlert(alert models.LogAlert) {
+	h.publish("alert", alert)
 }
 
 // HandleWebSocket handles WebSocket connections
@@ -261,7 +406,7 @@ func (h *Hub) sendInitialData(conn *websocket.Conn, namespace string) {
 		log.Printf("Failed to get initial pods: %v", err)
 	} else {
 		// Merge pod metrics into pods
-		podMetrics, metricsErr := h.k8sClient.GetPodMetrics(ctx, namespace)
+		podMetrics, metricsErr := h.podMetrics(ctx, namespace)
 		if metricsErr == nil {
 			metricsMap := make(map[string]models.PodMetrics)
 			for _, m := range podMetrics {
@@ -295,6 +440,19 @@ func (h *Hub) sendInitialData(conn *websocket.Conn, namespace string) {
 		conn.WriteMessage(websocket.TextMessage, data)
 	}
 
+	// Send recent metrics history so charts start filled
+	metricsHistory := h.metricsHistory.Query(history.Query{
+		Namespace: namespace,
+		From:      time.Now().Add(-initialHistoryRange),
+		To:        time.Now(),
+		Step:      initialHistoryStep,
+	})
+	data, _ := json.Marshal(map[string]interface{}{
+		"type": "metricsHistory",
+		"data": metricsHistory,
+	})
+	conn.WriteMessage(websocket.TextMessage, data)
+
 	// Send initial metrics
 	h.broadcastMetrics(ctx, namespace)
 }
@@ -0,0 +1,422 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+	"github.com/krzyzao/kub/internal/k8s"
+	"github.com/krzyzao/kub/internal/models"
+	"github.com/krzyzao/kub/internal/telemetry"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+const (
+	// Maximum stdin message size accepted from the terminal (pastes can be large)
+	maxExecMessageSize = 64 * 1024
+	// Default idle timeout when KUB_EXEC_IDLE_TIMEOUT is not set
+	defaultExecIdleTimeout = 15 * time.Minute
+	// Number of finished sessions kept for the audit endpoint
+	maxExecAuditRecords = 200
+)
+
+// defaultShells are tried in order when neither the client nor KUB_EXEC_COMMAND sets a command
+var defaultShells = []string{"bash", "sh"}
+
+// ExecHub manages interactive exec sessions and their audit records
+type ExecHub struct {
+	k8sClient      *k8s.Client
+	defaultCommand []string
+	idleTimeout    time.Duration
+
+	mu       sync.Mutex
+	active   map[string]*models.ExecSession
+	finished []models.ExecSession
+}
+
+// NewExecHub creates a new exec hub configured from KUB_EXEC_COMMAND and KUB_EXEC_IDLE_TIMEOUT
+func NewExecHub(k8sClient *k8s.Client) *ExecHub {
+	idleTimeout := defaultExecIdleTimeout
+	if v := os.Getenv("KUB_EXEC_IDLE_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			idleTimeout = d
+		} else {
+			log.Printf("Invalid KUB_EXEC_IDLE_TIMEOUT %q, using %s", v, defaultExecIdleTimeout)
+		}
+	}
+
+	return &ExecHub{
+		k8sClient:      k8sClient,
+		defaultCommand: strings.Fields(os.Getenv("KUB_EXEC_COMMAND")),
+		idleTimeout:    idleTimeout,
+		active:         make(map[string]*models.ExecSession),
+	}
+}
+
+// execMessage represents a terminal message in either direction
+type execMessage struct {
+	Type string `json:"type"` // client: 'stdin', 'resize'; server: 'stdout', 'stderr', 'exit', 'error'
+	Data string `json:"data,omitempty"`
+	Cols uint16 `json:"cols,omitempty"`
+	Rows uint16 `json:"rows,omitempty"`
+}
+
+// HandleExec handles WebSocket connections for interactive exec sessions
+func (e *ExecHub) HandleExec(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	namespace := query.Get("namespace")
+	podName := query.Get("pod")
+	container := query.Get("container")
+	command := query["command"]
+	tty := query.Get("tty") != "false"
+
+	if namespace == "" || podName == "" {
+		http.Error(w, "namespace and pod parameters are required", http.StatusBadRequest)
+		return
+	}
+	if !validateK8sName(namespace) || !validateK8sName(podName) || !validateK8sName(container) {
+		http.Error(w, "invalid namespace, pod or container parameter", http.StatusBadRequest)
+		return
+	}
+
+	if !checkOrigin(r) {
+		log.Printf("Rejected exec WebSocket connection from origin: %s", r.Header.Get("Origin"))
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	container, err := e.k8sClient.ResolveExecContainer(r.Context(), namespace, podName, container)
+	if err != nil {
+		respondError(w, err, http.StatusBadRequest, "failed to resolve container")
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade exec connection: %v", err)
+		return
+	}
+
+	telemetry.WebSocketClients.WithLabelValues("exec").Inc()
+	defer telemetry.WebSocketClients.WithLabelValues("exec").Dec()
+	defer conn.Close()
+
+	out := &execWriter{conn: conn}
+
+	if len(command) == 0 {
+		command = e.defaultCommand
+	}
+	if len(command) == 0 {
+		shell, err := e.k8sClient.FindShell(ctx, namespace, podName, container, defaultShells)
+		if err != nil {
+			out.send(execMessage{Type: "error", Data: err.Error()})
+			return
+		}
+		command = []string{shell}
+	}
+
+	session := e.startSession(namespace, podName, container, command, getClientIP(r))
+	endReason := "exited"
+	var exitCode *int
+	defer func() {
+		e.finishSession(session.ID, endReason, exitCode, out.bytes.Load())
+	}()
+
+	conn.SetReadLimit(maxExecMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	var lastActivity atomic.Int64
+	touch := func() { lastActivity.Store(time.Now().UnixNano()) }
+	touch()
+	out.onWrite = touch
+
+	stdinReader, stdinWriter := io.Pipe()
+	sizeQueue := newTerminalSizeQueue(ctx)
+
+	var closeReason atomic.Value
+	closeWith := func(reason string) {
+		closeReason.CompareAndSwap(nil, reason)
+		cancel()
+		stdinWriter.Close()
+	}
+
+	// Read stdin and resize messages from the client
+	go func() {
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+					log.Printf("Exec WebSocket read error: %v", err)
+				}
+				closeWith("client closed")
+				return
+			}
+
+			var msg execMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				continue
+			}
+
+			switch msg.Type {
+			case "stdin":
+				touch()
+				e.addBytesIn(session.ID, int64(len(msg.Data)))
+				if _, err := stdinWriter.Write([]byte(msg.Data)); err != nil {
+					return
+				}
+			case "resize":
+				if msg.Cols > 0 && msg.Rows > 0 {
+					sizeQueue.push(remotecommand.TerminalSize{Width: msg.Cols, Height: msg.Rows})
+				}
+			}
+		}
+	}()
+
+	// Keepalive pings and idle timeout
+	go func() {
+		ticker := time.NewTicker(pingPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				idle := time.Since(time.Unix(0, lastActivity.Load()))
+				if idle > e.idleTimeout {
+					out.send(execMessage{Type: "error", Data: "session closed after " + e.idleTimeout.String() + " of inactivity"})
+					closeWith("idle timeout")
+					return
+				}
+				if err := out.ping(); err != nil {
+					closeWith("client closed")
+					return
+				}
+			}
+		}
+	}()
+
+	log.Printf("Exec session %s started for %s/%s container %s: %v", session.ID, namespace, podName, container, command)
+
+	err = e.k8sClient.ExecInPod(ctx, namespace, podName, k8s.ExecOptions{
+		Container: container,
+		Command:   command,
+		TTY:       tty,
+		Stdin:     stdinReader,
+		Stdout:    out.stream("stdout"),
+		Stderr:    out.stream("stderr"),
+		SizeQueue: sizeQueue,
+	})
+	// Unblock the stdin reader goroutine if the client keeps typing after exit
+	stdinReader.Close()
+
+	if reason, ok := closeReason.Load().(string); ok {
+		endReason = reason
+		return
+	}
+
+	if code, ok := k8s.ExitCode(err); ok {
+		exitCode = &code
+		out.send(execMessage{Type: "exit", Data: strconv.Itoa(code)})
+		return
+	}
+
+	endReason = "error"
+	log.Printf("Exec session %s failed: %v", session.ID, err)
+	out.send(execMessage{Type: "error", Data: err.Error()})
+}
+
+// GetSessions returns active and recently finished exec sessions for auditing
+func (e *ExecHub) GetSessions(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	sessions := make([]models.ExecSession, 0, len(e.active)+len(e.finished))
+	for _, s := range e.active {
+		sessions = append(sessions, *s)
+	}
+	// Most recently finished first
+	for i := len(e.finished) - 1; i >= 0; i-- {
+		sessions = append(sessions, e.finished[i])
+	}
+	e.mu.Unlock()
+
+	respondJSON(w, sessions)
+}
+
+func (e *ExecHub) startSession(namespace, pod, container string, command []string, clientIP string) *models.ExecSession {
+	session := &models.ExecSession{
+		ID:        newSessionID(),
+		Namespace: namespace,
+		Pod:       pod,
+		Container: container,
+		Command:   command,
+		ClientIP:  clientIP,
+		StartedAt: time.Now(),
+	}
+
+	e.mu.Lock()
+	e.active[session.ID] = session
+	e.mu.Unlock()
+
+	return session
+}
+
+func (e *ExecHub) addBytesIn(id string, n int64) {
+	e.mu.Lock()
+	if s, ok := e.active[id]; ok {
+		s.BytesIn += n
+	}
+	e.mu.Unlock()
+}
+
+func (e *ExecHub) finishSession(id, reason string, exitCode *int, bytesOut int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s, ok := e.active[id]
+	if !ok {
+		return
+	}
+	delete(e.active, id)
+
+	now := time.Now()
+	s.EndedAt = &now
+	s.EndReason = reason
+	s.ExitCode = exitCode
+	s.BytesOut = bytesOut
+
+	e.finished = append(e.finished, *s)
+	if len(e.finished) > maxExecAuditRecords {
+		e.finished = e.finished[len(e.finished)-maxExecAuditRecords:]
+	}
+
+	log.Printf("AUDIT exec session=%s client=%s pod=%s/%s container=%s command=%q duration=%s reason=%s bytesIn=%d bytesOut=%d",
+		s.ID, s.ClientIP, s.Namespace, s.Pod, s.Container, strings.Join(s.Command, " "),
+		now.Sub(s.StartedAt).Round(time.Second), s.EndReason, s.BytesIn, s.BytesOut)
+}
+
+// execWriter serializes writes from the exec streams onto the WebSocket
+type execWriter struct {
+	conn    *websocket.Conn
+	mu      sync.Mutex
+	bytes   atomic.Int64
+	onWrite func()
+}
+
+func (w *execWriter) send(msg execMessage) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return sendJSON(w.conn, msg)
+}
+
+func (w *execWriter) ping() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return w.conn.WriteMessage(websocket.PingMessage, nil)
+}
+
+func (w *execWriter) stream(msgType string) io.Writer {
+	return &execStreamWriter{w: w, msgType: msgType}
+}
+
+// execStreamWriter sends one output stream as text messages. Each stream is
+// written by a single goroutine.
+type execStreamWriter struct {
+	w       *execWriter
+	msgType string
+	pending []byte // start of a UTF-8 rune split across writes
+}
+
+func (s *execStreamWriter) Write(p []byte) (int, error) {
+	data := append(s.pending, p...)
+	s.pending = nil
+	// Hold back an incomplete rune at the end until the rest arrives, so it
+	// isn't replaced with U+FFFD when the message is encoded
+	if cut := incompleteRuneStart(data); cut < len(data) {
+		s.pending = append([]byte(nil), data[cut:]...)
+		data = data[:cut]
+	}
+
+	if len(data) > 0 {
+		if err := s.w.send(execMessage{Type: s.msgType, Data: string(data)}); err != nil {
+			return 0, err
+		}
+	}
+	s.w.bytes.Add(int64(len(p)))
+	if s.w.onWrite != nil {
+		s.w.onWrite()
+	}
+	return len(p), nil
+}
+
+// incompleteRuneStart returns the index of a truncated UTF-8 sequence at the
+// end of p, or len(p) if p doesn't end in one
+func incompleteRuneStart(p []byte) int {
+	// A rune is at most utf8.UTFMax bytes, so only the tail can be cut short
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if !utf8.RuneStart(p[i]) {
+			continue
+		}
+		if !utf8.FullRune(p[i:]) {
+			return i
+		}
+		break
+	}
+	return len(p)
+}
+
+// terminalSizeQueue feeds resize messages to the remote TTY
+type terminalSizeQueue struct {
+	ctx   context.Context
+	sizes chan remotecommand.TerminalSize
+}
+
+func newTerminalSizeQueue(ctx context.Context) *terminalSizeQueue {
+	return &terminalSizeQueue{ctx: ctx, sizes: make(chan remotecommand.TerminalSize, 1)}
+}
+
+func (q *terminalSizeQueue) push(size remotecommand.TerminalSize) {
+	// Only the latest size matters, drop a pending one if the reader is behind
+	select {
+	case <-q.sizes:
+	default:
+	}
+	select {
+	case q.sizes <- size:
+	default:
+	}
+}
+
+// Next returns the next terminal size, or nil once the session ends
+func (q *terminalSizeQueue) Next() *remotecommand.TerminalSize {
+	select {
+	case <-q.ctx.Done():
+		return nil
+	case size := <-q.sizes:
+		return &size
+	}
+}
+
+func newSessionID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
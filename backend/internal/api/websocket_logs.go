@@ -8,10 +8,17 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/krzyzao/kub/internal/k8s"
+	"github.com/krzyzao/kub/internal/logarchive"
+	"github.com/krzyzao/kub/internal/logs"
+	"github.com/krzyzao/kub/internal/models"
+	"github.com/krzyzao/kub/internal/telemetry"
+	corev1 "k8s.io/api/core/v1"
 )
 
 const (
@@ -23,24 +30,60 @@ const (
 	writeWait = 10 * time.Second
 	// Maximum message size allowed from client
 	maxMessageSize = 512
+	// How often a followed job is checked for new pods
+	jobPollInterval = 2 * time.Second
 )
 
 // LogStreamHub manages individual log stream connections
 type LogStreamHub struct {
 	k8sClient *k8s.Client
+	archive   *logarchive.Store
 }
 
 // NewLogStreamHub creates a new log stream hub
-func NewLogStreamHub(k8sClient *k8s.Client) *LogStreamHub {
+func NewLogStreamHub(k8sClient *k8s.Client, archive *logarchive.Store) *LogStreamHub {
 	return &LogStreamHub{
 		k8sClient: k8sClient,
+		archive:   archive,
 	}
 }
 
 // logStreamMessage represents a message sent over the WebSocket
 type logStreamMessage struct {
-	Type string `json:"type"` // 'log', 'error', 'end'
-	Data string `json:"data"`
+	Type      string                   `json:"type"` // 'log', 'error', 'end'; 'restart' when following restarts; aggregated streams also 'attach', 'detach', 'limit'
+	Data      string                   `json:"data"`
+	Pod       string                   `json:"pod,omitempty"`       // set on aggregated streams
+	Container string                   `json:"container,omitempty"` // set on aggregated streams
+	Entry     *models.LogEntry         `json:"entry,omitempty"`     // set when parsing was requested
+	Restart   *models.ContainerRestart `json:"restart,omitempty"`   // set on 'restart' messages
+}
+
+// logProcessor builds the optional parser/filter from the parse, level and
+// (repeatable) field=key=value query parameters
+func logProcessor(query url.Values) (*logs.Processor, error) {
+	return logs.NewProcessor(query.Get("parse"), query.Get("level"), query["field"])
+}
+
+// processLogs parses and filters a block of log text, returning the kept lines
+// both as text and as parsed entries
+func processLogs(processor *logs.Processor, text string, timestamped bool) (string, []models.LogEntry) {
+	var kept strings.Builder
+	entries := []models.LogEntry{}
+
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		entry, keep := processor.Process(line, timestamped)
+		if !keep {
+			continue
+		}
+		kept.WriteString(line)
+		kept.WriteByte('\n')
+		entries = append(entries, entry)
+	}
+
+	return kept.String(), entries
 }
 
 // HandleLogStream handles WebSocket connections for log streaming
@@ -48,12 +91,21 @@ func (h *LogStreamHub) HandleLogStream(w http.ResponseWriter, r *http.Request) {
 	// Extract parameters from query
 	namespace := r.URL.Query().Get("namespace")
 	podName := r.URL.Query().Get("pod")
+	jobName := r.URL.Query().Get("job")
 	container := r.URL.Query().Get("container")
 	previous := r.URL.Query().Get("previous") == "true"
 	timestamps := r.URL.Query().Get("timestamps") == "true"
+	// Keep following the container when it restarts instead of ending the stream
+	followRestarts := r.URL.Query().Get("followRestarts") == "true"
 
-	if namespace == "" || podName == "" {
-		http.Error(w, "namespace and pod parameters are required", http.StatusBadRequest)
+	if namespace == "" || (podName == "") == (jobName == "") {
+		http.Error(w, "namespace and either pod or job parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	processor, err := logProcessor(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -70,6 +122,9 @@ func (h *LogStreamHub) HandleLogStream(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Failed to upgrade log stream connection: %v", err)
 		return
 	}
+
+	telemetry.WebSocketClients.WithLabelValues("logs").Inc()
+	defer telemetry.WebSocketClients.WithLabelValues("logs").Dec()
 	defer conn.Close()
 
 	// Configure connection for proper timeout handling
@@ -80,7 +135,11 @@ func (h *LogStreamHub) HandleLogStream(w http.ResponseWriter, r *http.Request) {
 		return nil
 	})
 
-	log.Printf("Log stream connected for pod %s/%s, container %s", namespace, podName, container)
+	if jobName != "" {
+		log.Printf("Log stream connected for job %s/%s, container %s", namespace, jobName, container)
+	} else {
+		log.Printf("Log stream connected for pod %s/%s, container %s", namespace, podName, container)
+	}
 
 	// Create context for this stream
 	ctx, cancel := context.WithCancel(r.Context())
@@ -90,7 +149,18 @@ func (h *LogStreamHub) HandleLogStream(w http.ResponseWriter, r *http.Request) {
 	logChan := make(chan string)
 	errChan := make(chan error)
 
-	go h.streamLogs(ctx, namespace, podName, container, previous, timestamps, logChan, errChan)
+	// Restart markers; stays nil (never ready) unless following restarts
+	var events chan logStreamMessage
+
+	switch {
+	case jobName != "":
+		go h.streamJobLogs(ctx, namespace, jobName, container, timestamps, logChan, errChan)
+	case followRestarts && !previous:
+		events = make(chan logStreamMessage)
+		go h.streamLogsAcrossRestarts(ctx, namespace, podName, container, timestamps, logChan, events, errChan)
+	default:
+		go h.streamLogs(ctx, namespace, podName, container, previous, timestamps, logChan, errChan)
+	}
 
 	// Send ping/pong keepalive
 	ticker := time.NewTicker(pingPeriod)
@@ -126,10 +196,22 @@ func (h *LogStreamHub) HandleLogStream(w http.ResponseWriter, r *http.Request) {
 				sendJSON(conn, logStreamMessage{Type: "end", Data: ""})
 				return
 			}
-			if err := sendJSON(conn, logStreamMessage{Type: "log", Data: line}); err != nil {
+			msg := logStreamMessage{Type: "log", Data: line}
+			if processor != nil {
+				entry, keep := processor.Process(strings.TrimRight(line, "\r\n"), timestamps)
+				if !keep {
+					continue
+				}
+				msg.Entry = &entry
+			}
+			if err := sendJSON(conn, msg); err != nil {
 				log.Printf("Error sending log line: %v", err)
 				return
 			}
+		case msg := <-events:
+			if err := sendJSON(conn, msg); err != nil {
+				return
+			}
 		case err, ok := <-errChan:
 			if !ok {
 				return
@@ -164,11 +246,100 @@ func (h *LogStreamHub) streamLogs(
 
 	stream, err := h.k8sClient.GetPodLogsStream(ctx, namespace, podName, logOpts)
 	if err != nil {
-		errChan <- fmt.Errorf("failed to get log stream: %w", err)
+		// A deleted pod's archived log is sent whole and the stream ends
+		archived, archiveErr := openArchivedLogs(h.k8sClient, h.archive, err, namespace, podName, container, nil)
+		if archiveErr != nil {
+			errChan <- fmt.Errorf("failed to get log stream: %w", archiveErr)
+			return
+		}
+		defer archived.Close()
+		copyArchivedLines(ctx, archived, timestamps, logChan)
 		return
 	}
 	defer stream.Close()
 
+	copyLogLines(ctx, namespace, podName, stream, logChan)
+}
+
+// streamJobLogs follows the pods of a job in creation order, waiting for pods
+// that have not started yet, until the job has finished and every pod's log
+// was streamed. Pods of parallel jobs are streamed one after another.
+func (h *LogStreamHub) streamJobLogs(
+	ctx context.Context,
+	namespace, jobName, container string,
+	timestamps bool,
+	logChan chan<- string,
+	errChan chan<- error,
+) {
+	defer close(logChan)
+	defer close(errChan)
+
+	streamed := make(map[string]bool)
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		pods, finished, err := h.k8sClient.GetJobPods(ctx, namespace, jobName)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		pending := false
+		for _, pod := range pods {
+			if streamed[pod.Name] {
+				continue
+			}
+			// Logs are not available before the containers start
+			if pod.Status.Phase == corev1.PodPending {
+				pending = true
+				break
+			}
+
+			streamed[pod.Name] = true
+			select {
+			case logChan <- fmt.Sprintf("==> pod/%s <==\n", pod.Name):
+			case <-ctx.Done():
+				return
+			}
+
+			stream, err := h.k8sClient.GetPodLogsStream(ctx, namespace, pod.Name, k8s.LogOptions{
+				Container:  container,
+				Timestamps: timestamps,
+			})
+			if err != nil {
+				log.Printf("Job log stream error for %s/%s: %v", namespace, pod.Name, err)
+				continue
+			}
+			copyLogLines(ctx, namespace, pod.Name, stream, logChan)
+			stream.Close()
+		}
+
+		// Pods streamed earlier may have been removed since (pod GC, replaced
+		// failed pods), so only the pods listed now are checked
+		if finished && !pending && allStreamed(pods, streamed) {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func allStreamed(pods []corev1.Pod, streamed map[string]bool) bool {
+	for _, pod := range pods {
+		if !streamed[pod.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+// copyLogLines sends lines from a log stream until it ends or ctx is cancelled
+func copyLogLines(ctx context.Context, namespace, podName string, stream io.Reader, logChan chan<- string) {
 	reader := bufio.NewReader(stream)
 
 	for {
@@ -188,7 +359,11 @@ func (h *LogStreamHub) streamLogs(
 			}
 
 			// Send line (preserving newline for formatting)
-			logChan <- line
+			select {
+			case logChan <- line:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}
 }
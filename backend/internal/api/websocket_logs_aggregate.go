@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/krzyzao/kub/internal/k8s"
+	"github.com/krzyzao/kub/internal/telemetry"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Initial lines per container when no tailLines is given, so attaching to
+// many pods does not replay their whole history
+const defaultAggregateTailLines = 100
+
+// maxLogStreams returns the per-connection limit of concurrent container
+// streams from KUB_LOG_MAX_STREAMS
+func maxLogStreams() int {
+	if v := os.Getenv("KUB_LOG_MAX_STREAMS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+		log.Printf("Invalid KUB_LOG_MAX_STREAMS %q, using default", v)
+	}
+	return k8s.DefaultMaxLogStreams
+}
+
+// HandleAggregateLogStream streams the logs of every pod matching a label
+// selector (?selector=) or belonging to a workload (?kind=Deployment&name=),
+// prefixing each line with pod/container
+func (h *LogStreamHub) HandleAggregateLogStream(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	namespace := query.Get("namespace")
+	kind := query.Get("kind")
+	name := query.Get("name")
+
+	if namespace == "" || !validateK8sName(namespace) || !validateK8sName(query.Get("container")) {
+		http.Error(w, "invalid namespace or container parameter", http.StatusBadRequest)
+		return
+	}
+
+	processor, err := logProcessor(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var tailLines int64 = defaultAggregateTailLines
+	if tl := query.Get("tailLines"); tl != "" {
+		parsed, err := strconv.ParseInt(tl, 10, 64)
+		if err != nil || parsed < 0 {
+			http.Error(w, "invalid tailLines parameter", http.StatusBadRequest)
+			return
+		}
+		tailLines = parsed
+	}
+
+	var selector string
+	switch {
+	case query.Get("selector") != "" && kind == "":
+		parsed, err := labels.Parse(query.Get("selector"))
+		if err != nil || parsed.Empty() {
+			http.Error(w, "invalid selector parameter", http.StatusBadRequest)
+			return
+		}
+		selector = parsed.String()
+	case kind != "" && name != "" && validateK8sName(name):
+		var err error
+		selector, err = h.k8sClient.WorkloadSelector(r.Context(), namespace, kind, name)
+		if err != nil {
+			respondError(w, err, http.StatusBadRequest, "failed to resolve workload")
+			return
+		}
+	default:
+		http.Error(w, "either selector or kind and name parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	if !checkOrigin(r) {
+		log.Printf("Rejected log stream WebSocket connection from origin: %s", r.Header.Get("Origin"))
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade log stream connection: %v", err)
+		return
+	}
+
+	telemetry.WebSocketClients.WithLabelValues("logs_aggregate").Inc()
+	defer telemetry.WebSocketClients.WithLabelValues("logs_aggregate").Dec()
+	defer conn.Close()
+
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	log.Printf("Aggregated log stream connected for %s in %s", selector, namespace)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	lines := make(chan k8s.LogLine, 256)
+	// Events are sent from inside the tailer lock, so they are buffered and
+	// dropped rather than blocking when the client is slow
+	events := make(chan k8s.LogTailEvent, 256)
+	timestamps := query.Get("timestamps") == "true"
+	tailer := h.k8sClient.NewLogTailer(namespace, k8s.LogTailOptions{
+		LabelSelector: selector,
+		Container:     query.Get("container"),
+		TailLines:     tailLines,
+		Timestamps:    timestamps,
+		MaxStreams:    maxLogStreams(),
+	}, lines, func(e k8s.LogTailEvent) {
+		select {
+		case events <- e:
+		default:
+		}
+	})
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- tailer.Run(ctx)
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				if !websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+					log.Printf("Log stream WebSocket read error: %v", err)
+				}
+				cancel()
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case line := <-lines:
+			msg := logStreamMessage{
+				Type:      "log",
+				Data:      fmt.Sprintf("[%s/%s] %s\n", line.Pod, line.Container, line.Text),
+				Pod:       line.Pod,
+				Container: line.Container,
+			}
+			if processor != nil {
+				entry, keep := processor.Process(line.Text, timestamps)
+				if !keep {
+					continue
+				}
+				msg.Entry = &entry
+			}
+			if err := sendJSON(conn, msg); err != nil {
+				log.Printf("Error sending log line: %v", err)
+				return
+			}
+		case e := <-events:
+			sendJSON(conn, logStreamMessage{Type: e.Type, Data: e.Message, Pod: e.Pod, Container: e.Container})
+		case err := <-errChan:
+			if err != nil {
+				sendJSON(conn, logStreamMessage{Type: "error", Data: err.Error()})
+			}
+			return
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/krzyzao/kub/internal/k8s"
+	"github.com/krzyzao/kub/internal/logs"
+	"github.com/krzyzao/kub/internal/models"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// How often the container status is checked while waiting for a restart
+const restartPollInterval = 2 * time.Second
+
+// logCursor remembers the last delivered line so a stream can be resumed with
+// sinceTime without duplicates. sinceTime has second precision and is
+// inclusive, so lines at the cursor's timestamp are remembered individually.
+type logCursor struct {
+	last *time.Time
+	seen map[string]bool
+}
+
+// accept reports whether a line has not been delivered yet and records it
+func (c *logCursor) accept(ts *time.Time, line string) bool {
+	if ts == nil {
+		return true
+	}
+	if c.last != nil {
+		if ts.Before(*c.last) {
+			return false
+		}
+		if ts.Equal(*c.last) {
+			if c.seen[line] {
+				return false
+			}
+			c.seen[line] = true
+			return true
+		}
+	}
+	c.last = ts
+	c.seen = map[string]bool{line: true}
+	return true
+}
+
+// streamLogsAcrossRestarts follows a container across restarts. When the
+// followed instance ends, it waits for the next one, sends the rest of the
+// terminated instance's log (previous=true), a "restart" marker with the exit
+// status and then follows the new instance from where it left off.
+func (h *LogStreamHub) streamLogsAcrossRestarts(
+	ctx context.Context,
+	namespace, podName, container string,
+	timestamps bool,
+	logChan chan<- string,
+	events chan<- logStreamMessage,
+	errChan chan<- error,
+) {
+	defer close(logChan)
+	defer close(errChan)
+
+	status, _, err := h.k8sClient.GetContainerStatus(ctx, namespace, podName, container)
+	if err != nil {
+		errChan <- err
+		return
+	}
+	var instance int32
+	if status != nil {
+		instance = status.RestartCount
+	}
+
+	cursor := &logCursor{}
+	ticker := time.NewTicker(restartPollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Timestamps are always requested to position the cursor
+		stream, err := h.k8sClient.GetPodLogsStream(ctx, namespace, podName, k8s.LogOptions{
+			Container:  container,
+			Timestamps: true,
+			SinceTime:  cursor.last,
+		})
+		if err == nil {
+			copyNewLogLines(ctx, stream, cursor, timestamps, logChan)
+			stream.Close()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			status, finished, err := h.k8sClient.GetContainerStatus(ctx, namespace, podName, container)
+			if apierrors.IsNotFound(err) {
+				return // pod deleted: the stream ends normally
+			}
+			if err != nil {
+				log.Printf("Log follow status error for %s/%s: %v", namespace, podName, err)
+				continue
+			}
+			if status == nil {
+				continue
+			}
+
+			switch {
+			case status.RestartCount > instance:
+				h.sendPreviousTail(ctx, namespace, podName, container, cursor, timestamps, logChan)
+				restart := containerRestart(status)
+				select {
+				case events <- logStreamMessage{Type: "restart", Data: restartMessage(restart), Restart: &restart}:
+				case <-ctx.Done():
+					return
+				}
+				instance = status.RestartCount
+				break wait
+			case status.State.Running != nil:
+				// Same instance still running: the connection dropped, resume it
+				break wait
+			case finished:
+				return // the container will not be restarted
+			}
+		}
+	}
+}
+
+// sendPreviousTail sends the lines of the terminated instance that the follow
+// stream did not deliver before it ended
+func (h *LogStreamHub) sendPreviousTail(ctx context.Context, namespace, podName, container string, cursor *logCursor, timestamps bool, logChan chan<- string) {
+	stream, err := h.k8sClient.OpenPodLogs(ctx, namespace, podName, k8s.LogOptions{
+		Container:  container,
+		Previous:   true,
+		Timestamps: true,
+		SinceTime:  cursor.last,
+	})
+	if err != nil {
+		log.Printf("Failed to get previous logs for %s/%s: %v", namespace, podName, err)
+		return
+	}
+	defer stream.Close()
+
+	copyNewLogLines(ctx, stream, cursor, timestamps, logChan)
+}
+
+// copyNewLogLines sends the timestamped lines of a stream the cursor has not
+// seen, removing the timestamp unless the client asked for it
+func copyNewLogLines(ctx context.Context, stream io.Reader, cursor *logCursor, timestamps bool, logChan chan<- string) {
+	reader := bufio.NewReader(stream)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			text := strings.TrimRight(line, "\r\n")
+			ts, message := logs.SplitTimestamp(text)
+			if cursor.accept(ts, message) {
+				if !timestamps {
+					text = message
+				}
+				select {
+				case logChan <- text + "\n":
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func containerRestart(status *corev1.ContainerStatus) models.ContainerRestart {
+	restart := models.ContainerRestart{RestartCount: status.RestartCount}
+	if t := status.LastTerminationState.Terminated; t != nil {
+		restart.ExitCode = t.ExitCode
+		restart.Signal = t.Signal
+		restart.Reason = t.Reason
+		restart.Message = t.Message
+		if !t.FinishedAt.IsZero() {
+			finished := t.FinishedAt.Time
+			restart.FinishedAt = &finished
+		}
+	}
+	return restart
+}
+
+func restartMessage(r models.ContainerRestart) string {
+	msg := fmt.Sprintf("container restarted (restart #%d): exit code %d", r.RestartCount, r.ExitCode)
+	if r.Reason != "" {
+		msg += ", reason " + r.Reason
+	}
+	return msg
+}
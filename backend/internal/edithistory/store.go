@@ -0,0 +1,136 @@
+// Package edithistory keeps a local, append-only record of edits made through
+// kub, one JSON lines file per object.
+package edithistory
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/krzyzao/kub/internal/models"
+)
+
+// unsafePathChars matches characters not allowed in a history path segment
+var unsafePathChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// DataDir returns the directory for kub's local state: KUB_DATA_DIR, or ~/.kub
+func DataDir() string {
+	if dir := os.Getenv("KUB_DATA_DIR"); dir != "" {
+		return dir
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".kub")
+	}
+	return ".kub"
+}
+
+// Store persists edit records below a root directory as
+// <context>/<kind>/<namespace>/<name>.jsonl
+type Store struct {
+	mu   sync.Mutex
+	root string
+}
+
+// NewStore creates a store rooted at dir
+func NewStore(dir string) *Store {
+	return &Store{root: dir}
+}
+
+// Append records an edit
+func (s *Store) Append(record models.EditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.path(record.Context, record.Kind, record.Namespace, record.Name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer f.Close()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode history record: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history record: %w", err)
+	}
+	return nil
+}
+
+// List returns the edits of an object, newest first
+func (s *Store) List(context, kind, namespace, name string) ([]models.EditRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path(context, kind, namespace, name))
+	if os.IsNotExist(err) {
+		return []models.EditRecord{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer f.Close()
+
+	var records []models.EditRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record models.EditRecord
+		// Skip lines damaged by a crash mid-write
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	if records == nil {
+		records = []models.EditRecord{}
+	}
+	return records, nil
+}
+
+// Find returns a single edit of an object by ID, or nil if there is none
+func (s *Store) Find(context, kind, namespace, name, id string) (*models.EditRecord, error) {
+	records, err := s.List(context, kind, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	for i := range records {
+		if records[i].ID == id {
+			return &records[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *Store) path(context, kind, namespace, name string) string {
+	if namespace == "" {
+		namespace = "_cluster"
+	}
+	return filepath.Join(s.root, safeSegment(context), safeSegment(kind), safeSegment(namespace), safeSegment(name)+".jsonl")
+}
+
+// safeSegment makes a value usable as a single path element (context names
+// are free-form, e.g. ARNs)
+func safeSegment(s string) string {
+	s = unsafePathChars.ReplaceAllString(s, "_")
+	if s == "" || s == "." || s == ".." {
+		return "_"
+	}
+	return s
+}
@@ -0,0 +1,357 @@
+package history
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krzyzao/kub/internal/models"
+)
+
+const (
+	dayLayout        = "2006-01-02"
+	segmentSuffix    = ".jsonl"
+	compactedSuffix  = ".jsonl.gz"
+	maintainInterval = time.Hour
+	// Days older than DefaultCompactAfter are averaged to DefaultCompactStep
+	DefaultCompactAfter = 7 * 24 * time.Hour
+	DefaultCompactStep  = 10 * time.Minute
+)
+
+// unsafePathChars matches characters not allowed in a cluster directory name
+var unsafePathChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// diskRecord is one line of a segment: the points of every series for one step
+type diskRecord struct {
+	Timestamp int64       `json:"t"` // unix milliseconds
+	Series    []diskPoint `json:"s"`
+}
+
+type diskPoint struct {
+	Kind      string `json:"k"`
+	Namespace string `json:"ns,omitempty"`
+	Name      string `json:"n"`
+	CPU       int64  `json:"c"`
+	Memory    int64  `json:"m"`
+	CPUMax    int64  `json:"cx,omitempty"` // highest sample of the step; absent before it was kept
+	MemoryMax int64  `json:"mx,omitempty"`
+}
+
+func newDiskPoint(kind, namespace, name string, p models.MetricsPoint) diskPoint {
+	return diskPoint{
+		Kind:      kind,
+		Namespace: namespace,
+		Name:      name,
+		CPU:       p.CPUUsage,
+		Memory:    p.MemoryUsage,
+		CPUMax:    p.CPUMax,
+		MemoryMax: p.MemoryMax,
+	}
+}
+
+func (p diskPoint) point(ts int64) models.MetricsPoint {
+	return models.MetricsPoint{Timestamp: ts, CPUUsage: p.CPU, MemoryUsage: p.Memory, CPUMax: p.CPUMax, MemoryMax: p.MemoryMax}
+}
+
+func (p diskPoint) key() string {
+	return p.Kind + "/" + p.Namespace + "/" + p.Name
+}
+
+// Disk persists downsampled metrics per cluster in daily append-only
+// segments <cluster>/<YYYY-MM-DD>.jsonl. Days past the compaction age are
+// averaged to a coarser step and gzipped; days past the retention are removed.
+type Disk struct {
+	mu           sync.Mutex
+	root         string
+	retention    time.Duration
+	compactAfter time.Duration
+	compactStep  time.Duration
+	lastMaintain time.Time
+}
+
+// NewDisk creates a disk store rooted at dir
+func NewDisk(dir string, retention time.Duration) *Disk {
+	return &Disk{
+		root:         dir,
+		retention:    retention,
+		compactAfter: DefaultCompactAfter,
+		compactStep:  DefaultCompactStep,
+	}
+}
+
+// Append writes the points of one step. Retention and compaction run in the
+// background at most once per hour.
+func (d *Disk) Append(cluster string, ts int64, points []diskPoint) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if now := time.Now(); now.Sub(d.lastMaintain) >= maintainInterval {
+		d.lastMaintain = now
+		go func() {
+			if err := d.Maintain(now); err != nil {
+				log.Printf("Metrics store maintenance failed: %v", err)
+			}
+		}()
+	}
+
+	dir := filepath.Join(d.root, safeCluster(cluster))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create metrics directory: %w", err)
+	}
+
+	day := time.UnixMilli(ts).UTC().Format(dayLayout)
+	f, err := os.OpenFile(filepath.Join(dir, day+segmentSuffix), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open metrics segment: %w", err)
+	}
+	defer f.Close()
+
+	data, err := json.Marshal(diskRecord{Timestamp: ts, Series: points})
+	if err != nil {
+		return fmt.Errorf("failed to encode metrics: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write metrics segment: %w", err)
+	}
+	return nil
+}
+
+// Read calls fn for every record of a cluster with from <= timestamp <= to,
+// oldest first. Only listing the segments holds the lock, so appends and
+// maintenance go on during long reads.
+func (d *Disk) Read(cluster string, from, to int64, fn func(diskRecord)) error {
+	dir := filepath.Join(d.root, safeCluster(cluster))
+	d.mu.Lock()
+	segments := listDays(dir)
+	d.mu.Unlock()
+
+	for _, seg := range segments {
+		dayStart := seg.day.UnixMilli()
+		if dayStart > to || dayStart+24*time.Hour.Milliseconds() <= from {
+			continue
+		}
+		path := seg.path
+		if !seg.compacted {
+			// Compacted since it was listed
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				path = strings.TrimSuffix(path, segmentSuffix) + compactedSuffix
+			}
+		}
+		err := readSegment(path, func(rec diskRecord) {
+			if rec.Timestamp >= from && rec.Timestamp <= to {
+				fn(rec)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Maintain removes days past the retention and compacts days past the
+// compaction age, for every cluster
+func (d *Disk) Maintain(now time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	clusters, err := os.ReadDir(d.root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read metrics directory: %w", err)
+	}
+
+	for _, c := range clusters {
+		if !c.IsDir() {
+			continue
+		}
+		for _, seg := range listDays(filepath.Join(d.root, c.Name())) {
+			// A day is judged by its end, so it is only touched once complete
+			dayEnd := seg.day.Add(24 * time.Hour)
+			switch {
+			case d.retention > 0 && now.Sub(dayEnd) > d.retention:
+				if err := os.Remove(seg.path); err != nil {
+					return fmt.Errorf("failed to remove metrics segment: %w", err)
+				}
+			case !seg.compacted && now.Sub(dayEnd) > d.compactAfter:
+				if err := compactSegment(seg.path, d.compactStep); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+type daySegment struct {
+	path      string
+	day       time.Time
+	compacted bool
+}
+
+// listDays returns the segments of a cluster directory, oldest first
+func listDays(dir string) []daySegment {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var segments []daySegment
+	for _, e := range entries {
+		name := e.Name()
+		seg := daySegment{path: filepath.Join(dir, name)}
+		switch {
+		case strings.HasSuffix(name, compactedSuffix):
+			name = strings.TrimSuffix(name, compactedSuffix)
+			seg.compacted = true
+		case strings.HasSuffix(name, segmentSuffix):
+			name = strings.TrimSuffix(name, segmentSuffix)
+		default:
+			continue
+		}
+		day, err := time.Parse(dayLayout, name)
+		if err != nil {
+			continue
+		}
+		seg.day = day
+		segments = append(segments, seg)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].day.Before(segments[j].day) })
+	return segments
+}
+
+func readSegment(path string, fn func(diskRecord)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open metrics segment: %w", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, compactedSuffix) {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("failed to read metrics segment: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var rec diskRecord
+		// A line cut short by a crash is skipped
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err == nil {
+			fn(rec)
+		}
+	}
+	return scanner.Err()
+}
+
+// compactSegment averages a day's points into steps, keeping their maximum,
+// and replaces the segment with a gzipped one
+func compactSegment(path string, step time.Duration) error {
+	type sum struct {
+		point diskPoint
+		count int64
+	}
+	steps := make(map[int64]map[string]*sum)
+
+	err := readSegment(path, func(rec diskRecord) {
+		start := rec.Timestamp - rec.Timestamp%step.Milliseconds()
+		bucket := steps[start]
+		if bucket == nil {
+			bucket = make(map[string]*sum)
+			steps[start] = bucket
+		}
+		for _, p := range rec.Series {
+			s := bucket[p.key()]
+			if s == nil {
+				s = &sum{point: diskPoint{Kind: p.Kind, Namespace: p.Namespace, Name: p.Name}}
+				bucket[p.key()] = s
+			}
+			s.point.CPU += p.CPU
+			s.point.Memory += p.Memory
+			peak := p.point(0)
+			s.point.CPUMax = max(s.point.CPUMax, peak.CPUPeak())
+			s.point.MemoryMax = max(s.point.MemoryMax, peak.MemoryPeak())
+			s.count++
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	starts := make([]int64, 0, len(steps))
+	for start := range steps {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+	target := strings.TrimSuffix(path, segmentSuffix) + compactedSuffix
+	tmp := target + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create compacted segment: %w", err)
+	}
+	gz := gzip.NewWriter(f)
+	enc := json.NewEncoder(gz)
+
+	for _, start := range starts {
+		rec := diskRecord{Timestamp: start}
+		for _, s := range steps[start] {
+			p := s.point
+			p.CPU /= s.count
+			p.Memory /= s.count
+			rec.Series = append(rec.Series, p)
+		}
+		if err = enc.Encode(rec); err != nil {
+			break
+		}
+	}
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write compacted segment: %w", err)
+	}
+
+	if err := os.Rename(tmp, target); err != nil {
+		return fmt.Errorf("failed to replace metrics segment: %w", err)
+	}
+	return os.Remove(path)
+}
+
+// safeCluster turns a cluster key (an API server URL) into a directory name:
+// the readable part plus a hash, so URLs differing only in replaced
+// characters don't share a directory
+func safeCluster(cluster string) string {
+	if cluster == "" {
+		return "_"
+	}
+	sum := sha256.Sum256([]byte(cluster))
+	name := strings.TrimPrefix(strings.TrimPrefix(cluster, "https://"), "http://")
+	return unsafePathChars.ReplaceAllString(name, "_") + "-" + hex.EncodeToString(sum[:4])
+}
@@ -0,0 +1,45 @@
+package history
+
+import "github.com/krzyzao/kub/internal/models"
+
+// ring is a bounded buffer of points in time order. It grows as points are
+// pushed, so short-lived series stay small; once at capacity, the oldest
+// point is overwritten.
+type ring struct {
+	points   []models.MetricsPoint
+	capacity int
+	start    int // index of the oldest point once full
+}
+
+func newRing(capacity int) *ring {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &ring{capacity: capacity}
+}
+
+func (r *ring) push(p models.MetricsPoint) {
+	if len(r.points) < r.capacity {
+		r.points = append(r.points, p)
+		return
+	}
+	r.points[r.start] = p
+	r.start = (r.start + 1) % len(r.points)
+}
+
+// at returns the i-th oldest point
+func (r *ring) at(i int) models.MetricsPoint {
+	return r.points[(r.start+i)%len(r.points)]
+}
+
+// between returns the points with from <= timestamp <= to, oldest first
+func (r *ring) between(from, to int64) []models.MetricsPoint {
+	var result []models.MetricsPoint
+	for i := range r.points {
+		p := r.at(i)
+		if p.Timestamp >= from && p.Timestamp <= to {
+			result = append(result, p)
+		}
+	}
+	return result
+}
@@ -0,0 +1,432 @@
+// Package history keeps CPU and memory time series per node, pod and
+// namespace. Recent samples are kept in memory as taken; older data is kept
+// averaged into coarser steps, with the maximum of each step, in memory and
+// optionally on disk per cluster.
+package history
+
+import (
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/krzyzao/kub/internal/models"
+)
+
+// Series kinds
+const (
+	KindNode      = "node"
+	KindPod       = "pod"
+	KindNamespace = "namespace"
+)
+
+// Defaults for the KUB_METRICS_HISTORY* variables
+const (
+	DefaultInterval      = 5 * time.Second
+	DefaultRawRetention  = time.Hour
+	DefaultRetention     = 24 * time.Hour
+	DefaultStep          = time.Minute
+	DefaultDiskRetention = 30 * 24 * time.Hour
+)
+
+// Config sets how much history is kept
+type Config struct {
+	Interval     time.Duration // expected time between samples
+	RawRetention time.Duration // samples are kept as taken this long
+	Retention    time.Duration // downsampled data is kept this long
+	Step         time.Duration // step of the downsampled data
+	// How long downsampled data is kept on disk; 0 = not persisted
+	DiskRetention time.Duration
+}
+
+// ConfigFromEnv reads KUB_METRICS_HISTORY (retention), KUB_METRICS_HISTORY_RAW
+// (full resolution retention), KUB_METRICS_HISTORY_STEP (downsampling step)
+// and KUB_METRICS_STORE_RETENTION (on-disk retention, 0 disables the disk store)
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Interval:      DefaultInterval,
+		RawRetention:  durationEnv("KUB_METRICS_HISTORY_RAW", DefaultRawRetention),
+		Retention:     durationEnv("KUB_METRICS_HISTORY", DefaultRetention),
+		Step:          durationEnv("KUB_METRICS_HISTORY_STEP", DefaultStep),
+		DiskRetention: DefaultDiskRetention,
+	}
+	if v := os.Getenv("KUB_METRICS_STORE_RETENTION"); v == "0" {
+		cfg.DiskRetention = 0
+	} else {
+		cfg.DiskRetention = durationEnv("KUB_METRICS_STORE_RETENTION", DefaultDiskRetention)
+	}
+	return cfg
+}
+
+func durationEnv(name string, def time.Duration) time.Duration {
+	if v := os.Getenv(name); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+		log.Printf("Invalid %s %q, using default", name, v)
+	}
+	return def
+}
+
+// Query selects series and the time range and resolution of their points
+type Query struct {
+	Kind      string // empty = every kind
+	Namespace string // restricts pods and namespaces; empty = all
+	Name      string // empty = every series of the kind
+	From      time.Time
+	To        time.Time
+	Step      time.Duration // 0 = the stored resolution
+	// Max asks metrics providers for the highest usage within each step too.
+	// The store always keeps it.
+	Max bool
+}
+
+// Store holds the time series of the current cluster
+type Store struct {
+	mu      sync.RWMutex
+	cfg     Config
+	disk    *Disk              // nil = not persisted
+	cluster string             // key of the cluster, see k8s.Client.ClusterKey
+	series  map[string]*series // kind/namespace/name
+}
+
+type series struct {
+	kind, namespace, name string
+	raw                   *ring
+	downsampled           *ring
+	bucket                bucket // downsampled point being accumulated
+	lastSeen              int64
+}
+
+type bucket struct {
+	start             int64
+	cpu, memory       int64
+	cpuMax, memoryMax int64
+	count             int64
+}
+
+func (b *bucket) add(p models.MetricsPoint) {
+	b.cpu += p.CPUUsage
+	b.memory += p.MemoryUsage
+	b.cpuMax = max(b.cpuMax, p.CPUPeak())
+	b.memoryMax = max(b.memoryMax, p.MemoryPeak())
+	b.count++
+}
+
+func (b bucket) point() models.MetricsPoint {
+	return models.MetricsPoint{
+		Timestamp:   b.start,
+		CPUUsage:    b.cpu / b.count,
+		MemoryUsage: b.memory / b.count,
+		CPUMax:      b.cpuMax,
+		MemoryMax:   b.memoryMax,
+	}
+}
+
+// NewStore creates an empty store
+func NewStore(cfg Config) *Store {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Step < cfg.Interval {
+		cfg.Step = cfg.Interval
+	}
+	return &Store{cfg: cfg, series: make(map[string]*series)}
+}
+
+// SetDisk persists downsampled data to d and loads it back whenever the
+// store switches to a cluster
+func (s *Store) SetDisk(d *Disk) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.disk = d
+}
+
+// Record adds a sample of every node and pod of a cluster, and of every
+// namespace as the sum of its pods. When the cluster differs from the
+// previous sample's, the series of the new cluster replace the current ones.
+// Series not seen for the retention period are dropped.
+func (s *Store) Record(cluster string, ts time.Time, nodes []models.NodeMetrics, pods []models.PodMetrics) {
+	now := ts.UnixMilli()
+
+	namespaces := make(map[string]*models.MetricsPoint)
+	for _, p := range pods {
+		ns := namespaces[p.Namespace]
+		if ns == nil {
+			ns = &models.MetricsPoint{Timestamp: now}
+			namespaces[p.Namespace] = ns
+		}
+		ns.CPUUsage += p.CPUUsage
+		ns.MemoryUsage += p.MemoryUsage
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cluster != s.cluster || s.series == nil {
+		s.switchCluster(cluster)
+	}
+
+	// Steps completed by this sample, persisted together
+	completed := make(map[int64][]diskPoint)
+
+	for _, n := range nodes {
+		s.add(KindNode, "", n.Name, models.MetricsPoint{Timestamp: now, CPUUsage: n.CPUUsage, MemoryUsage: n.MemoryUsage}, completed)
+	}
+	for _, p := range pods {
+		s.add(KindPod, p.Namespace, p.Name, models.MetricsPoint{Timestamp: now, CPUUsage: p.CPUUsage, MemoryUsage: p.MemoryUsage}, completed)
+	}
+	for name, point := range namespaces {
+		s.add(KindNamespace, "", name, *point, completed)
+	}
+
+	s.persist(completed)
+
+	expired := now - s.cfg.Retention.Milliseconds()
+	for key, sr := range s.series {
+		if sr.lastSeen < expired {
+			delete(s.series, key)
+		}
+	}
+}
+
+// switchCluster replaces the series with those of cluster, loading its
+// persisted history. Must be called with s.mu held.
+func (s *Store) switchCluster(cluster string) {
+	// Keep the steps the previous cluster was accumulating
+	if s.disk != nil && len(s.series) > 0 {
+		completed := make(map[int64][]diskPoint)
+		for _, sr := range s.series {
+			if sr.bucket.count > 0 {
+				point := sr.bucket.point()
+				completed[point.Timestamp] = append(completed[point.Timestamp], newDiskPoint(sr.kind, sr.namespace, sr.name, point))
+			}
+		}
+		s.persist(completed)
+	}
+
+	s.cluster = cluster
+	s.series = make(map[string]*series)
+	if s.disk == nil {
+		return
+	}
+
+	now := time.Now()
+	err := s.disk.Read(cluster, now.Add(-s.cfg.Retention).UnixMilli(), now.UnixMilli(), func(rec diskRecord) {
+		for _, p := range rec.Series {
+			sr := s.seriesFor(p.Kind, p.Namespace, p.Name)
+			sr.downsampled.push(p.point(rec.Timestamp))
+			sr.lastSeen = rec.Timestamp
+		}
+	})
+	if err != nil {
+		log.Printf("Failed to load metrics history of %s: %v", cluster, err)
+	}
+}
+
+// persist writes completed steps to disk. Must be called with s.mu held.
+func (s *Store) persist(completed map[int64][]diskPoint) {
+	if s.disk == nil {
+		return
+	}
+	for start, points := range completed {
+		if err := s.disk.Append(s.cluster, start, points); err != nil {
+			log.Printf("Failed to persist metrics: %v", err)
+		}
+	}
+}
+
+// seriesFor returns a series, creating it. Must be called with s.mu held.
+func (s *Store) seriesFor(kind, namespace, name string) *series {
+	key := kind + "/" + namespace + "/" + name
+	sr := s.series[key]
+	if sr == nil {
+		sr = &series{
+			kind:        kind,
+			namespace:   namespace,
+			name:        name,
+			raw:         newRing(int(s.cfg.RawRetention / s.cfg.Interval)),
+			downsampled: newRing(int(s.cfg.Retention / s.cfg.Step)),
+		}
+		s.series[key] = sr
+	}
+	return sr
+}
+
+// add appends a point to a series, adding the downsampled point of a
+// completed step to completed. Must be called with s.mu held.
+func (s *Store) add(kind, namespace, name string, p models.MetricsPoint, completed map[int64][]diskPoint) {
+	sr := s.seriesFor(kind, namespace, name)
+
+	sr.raw.push(p)
+	sr.lastSeen = p.Timestamp
+
+	step := s.cfg.Step.Milliseconds()
+	start := p.Timestamp - p.Timestamp%step
+	if sr.bucket.count > 0 && sr.bucket.start != start {
+		point := sr.bucket.point()
+		sr.downsampled.push(point)
+		completed[point.Timestamp] = append(completed[point.Timestamp], newDiskPoint(kind, namespace, name, point))
+		sr.bucket = bucket{}
+	}
+	sr.bucket.start = start
+	sr.bucket.add(p)
+}
+
+// Query returns the matching series, sorted by kind, namespace and name.
+// Ranges within the raw retention are answered at full resolution, older ones
+// from the downsampled data, read from disk when they reach past the memory
+// retention; a larger step averages points together, keeping their maximum.
+func (s *Store) Query(q Query) models.MetricsHistory {
+	s.mu.RLock()
+
+	now := time.Now()
+	from, to := q.From.UnixMilli(), q.To.UnixMilli()
+	raw := q.From.After(now.Add(-s.cfg.RawRetention))
+
+	step := s.cfg.Step.Milliseconds()
+	if raw {
+		step = s.cfg.Interval.Milliseconds()
+	}
+	if q.Step.Milliseconds() > step {
+		step = q.Step.Milliseconds()
+	}
+
+	var found []models.MetricsSeries
+	switch {
+	case raw:
+		for _, sr := range s.series {
+			if sr.matches(q) {
+				found = append(found, sr.info(sr.raw.between(from, to)))
+			}
+		}
+		s.mu.RUnlock()
+	case s.disk != nil && q.From.Before(now.Add(-s.cfg.Retention)):
+		// Disk is read without the lock so recording isn't held up
+		disk, cluster := s.disk, s.cluster
+		current := make(map[string][]models.MetricsPoint)
+		for key, sr := range s.series {
+			if sr.matches(q) {
+				current[key] = sr.current(from, to)
+			}
+		}
+		s.mu.RUnlock()
+		found = queryDisk(disk, cluster, q, from, to, current)
+	default:
+		for _, sr := range s.series {
+			if sr.matches(q) {
+				found = append(found, sr.info(append(sr.downsampled.between(from, to), sr.current(from, to)...)))
+			}
+		}
+		s.mu.RUnlock()
+	}
+
+	result := models.MetricsHistory{From: from, To: to, Step: step, Series: []models.MetricsSeries{}}
+	for _, sr := range found {
+		if q.Step > 0 {
+			sr.Points = resample(sr.Points, step)
+		}
+		if len(sr.Points) > 0 {
+			result.Series = append(result.Series, sr)
+		}
+	}
+
+	sort.Slice(result.Series, func(i, j int) bool {
+		a, b := result.Series[i], result.Series[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return result
+}
+
+// queryDisk reads the persisted points of the matching series of a cluster,
+// adding current, the steps still being accumulated in memory by series key
+func queryDisk(disk *Disk, cluster string, q Query, from, to int64, current map[string][]models.MetricsPoint) []models.MetricsSeries {
+	found := make(map[string]*models.MetricsSeries)
+	err := disk.Read(cluster, from, to, func(rec diskRecord) {
+		for _, p := range rec.Series {
+			sr := &series{kind: p.Kind, namespace: p.Namespace, name: p.Name}
+			if !sr.matches(q) {
+				continue
+			}
+			f := found[p.key()]
+			if f == nil {
+				info := sr.info(nil)
+				f = &info
+				found[p.key()] = f
+			}
+			f.Points = append(f.Points, p.point(rec.Timestamp))
+		}
+	})
+	if err != nil {
+		log.Printf("Failed to read metrics history of %s: %v", cluster, err)
+	}
+
+	for key, points := range current {
+		if f := found[key]; f != nil {
+			f.Points = append(f.Points, points...)
+		}
+	}
+
+	result := make([]models.MetricsSeries, 0, len(found))
+	for _, f := range found {
+		result = append(result, *f)
+	}
+	return result
+}
+
+func (sr *series) info(points []models.MetricsPoint) models.MetricsSeries {
+	return models.MetricsSeries{Kind: sr.kind, Name: sr.name, Namespace: sr.namespace, Points: points}
+}
+
+// current returns the downsampled point still being accumulated, if in range
+func (sr *series) current(from, to int64) []models.MetricsPoint {
+	if sr.bucket.count > 0 && sr.bucket.start >= from && sr.bucket.start <= to {
+		return []models.MetricsPoint{sr.bucket.point()}
+	}
+	return nil
+}
+
+func (sr *series) matches(q Query) bool {
+	if q.Kind != "" && sr.kind != q.Kind {
+		return false
+	}
+	if q.Name != "" && sr.name != q.Name {
+		return false
+	}
+	if q.Namespace != "" {
+		switch sr.kind {
+		case KindPod:
+			return sr.namespace == q.Namespace
+		case KindNamespace:
+			return sr.name == q.Namespace
+		}
+	}
+	return true
+}
+
+// resample averages points into buckets of step milliseconds, keeping the
+// highest usage of each
+func resample(points []models.MetricsPoint, step int64) []models.MetricsPoint {
+	var result []models.MetricsPoint
+	var b bucket
+	for _, p := range points {
+		start := p.Timestamp - p.Timestamp%step
+		if b.count > 0 && b.start != start {
+			result = append(result, b.point())
+			b = bucket{}
+		}
+		b.start = start
+		b.add(p)
+	}
+	if b.count > 0 {
+		result = append(result, b.point())
+	}
+	return result
+}
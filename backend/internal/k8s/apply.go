@@ -0,0 +1,229 @@
+package k8s
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/krzyzao/kub/internal/models"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+	"sigs.k8s.io/yaml"
+)
+
+// FieldManager identifies kub as the owner of fields it applies
+const FieldManager = "kub"
+
+// ApplyOptions represents options for applying a manifest
+type ApplyOptions struct {
+	DefaultNamespace string // used for namespaced objects without metadata.namespace
+	DryRun           bool
+	Force            bool // take ownership of fields managed by other field managers
+}
+
+// ApplyManifest server-side applies every document of a multi-document YAML
+// (or JSON) manifest. Documents are applied in order and each gets its own
+// result, so one invalid document does not hide the outcome of the others.
+func (c *Client) ApplyManifest(ctx context.Context, manifest []byte, opts ApplyOptions) ([]models.ApplyResult, error) {
+	docs, err := splitManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.ApplyResult, 0, len(docs))
+	for i, doc := range docs {
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		results = append(results, c.applyDocument(ctx, i, doc, opts))
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("manifest contains no objects")
+	}
+
+	return results, nil
+}
+
+func (c *Client) applyDocument(ctx context.Context, index int, doc []byte, opts ApplyOptions) models.ApplyResult {
+	result := models.ApplyResult{Index: index}
+
+	data, err := yaml.YAMLToJSON(doc)
+	if err != nil {
+		result.Error = fmt.Sprintf("invalid YAML: %v", err)
+		return result
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(data); err != nil {
+		result.Error = fmt.Sprintf("invalid object: %v", err)
+		return result
+	}
+
+	result.APIVersion = obj.GetAPIVersion()
+	result.Kind = obj.GetKind()
+	result.Name = obj.GetName()
+
+	if obj.GetName() == "" {
+		result.Error = "metadata.name is required"
+		return result
+	}
+
+	resource, namespace, err := c.resourceFor(obj.GroupVersionKind(), obj.GetNamespace(), opts.DefaultNamespace)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Namespace = namespace
+	if namespace != "" {
+		obj.SetNamespace(namespace)
+		data, _ = obj.MarshalJSON()
+	}
+
+	live, err := resource.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		result.Error = fmt.Sprintf("failed to get live object: %v", err)
+		return result
+	}
+	if apierrors.IsNotFound(err) {
+		live = nil
+	}
+
+	patchOpts := metav1.PatchOptions{
+		FieldManager: FieldManager,
+		Force:        &opts.Force,
+	}
+	if opts.DryRun {
+		patchOpts.DryRun = []string{metav1.DryRunAll}
+	}
+
+	applied, err := resource.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, patchOpts)
+	if err != nil {
+		result.Error, result.Causes = applyErrorDetails(err)
+		return result
+	}
+
+	var before interface{}
+	if live != nil {
+		before = cleanObject(live, true).Object
+	}
+	diff, err := yamlDiff("live", "applied", before, cleanObject(applied, true).Object)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Diff = diff
+	switch {
+	case live == nil:
+		result.Action = "created"
+	case diff == "":
+		result.Action = "unchanged"
+	default:
+		result.Action = "configured"
+	}
+
+	return result
+}
+
+// resourceFor maps a group/kind to its dynamic resource client and effective
+// namespace (empty for cluster-scoped kinds)
+func (c *Client) resourceFor(gvk schema.GroupVersionKind, namespace, defaultNamespace string) (dynamic.ResourceInterface, string, error) {
+	mapping, err := c.RESTMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		// The discovery cache never expires on its own; re-discover once in
+		// case the kind comes from a CRD installed since it was filled
+		if mapper, ok := c.RESTMapper.(*restmapper.DeferredDiscoveryRESTMapper); ok {
+			mapper.Reset()
+			mapping, err = c.RESTMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		}
+	}
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, "", fmt.Errorf("unknown kind %s in version %q (is the CRD installed?)", gvk.GroupKind().String(), gvk.Version)
+		}
+		return nil, "", fmt.Errorf("failed to map kind %s: %w", gvk.GroupKind().String(), err)
+	}
+
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return c.DynamicClient.Resource(mapping.Resource), "", nil
+	}
+
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+	return c.DynamicClient.Resource(mapping.Resource).Namespace(namespace), namespace, nil
+}
+
+// cleanObject returns a copy without server-populated metadata (and optionally
+// status), suitable for diffing or re-applying
+func cleanObject(obj *unstructured.Unstructured, stripStatus bool) *unstructured.Unstructured {
+	clean := obj.DeepCopy()
+	clean.SetManagedFields(nil)
+	clean.SetResourceVersion("")
+	clean.SetUID("")
+	clean.SetGeneration(0)
+	clean.SetCreationTimestamp(metav1.Time{})
+	clean.SetSelfLink("")
+	unstructured.RemoveNestedField(clean.Object, "metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration")
+	if len(clean.GetAnnotations()) == 0 {
+		unstructured.RemoveNestedField(clean.Object, "metadata", "annotations")
+	}
+	if stripStatus {
+		unstructured.RemoveNestedField(clean.Object, "status")
+	}
+	return clean
+}
+
+// applyErrorDetails extracts the message and field causes from an API error
+func applyErrorDetails(err error) (string, []models.ApplyCause) {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return err.Error(), nil
+	}
+
+	s := status.Status()
+	var causes []models.ApplyCause
+	if s.Details != nil {
+		for _, cause := range s.Details.Causes {
+			causes = append(causes, models.ApplyCause{
+				Field:   cause.Field,
+				Message: cause.Message,
+			})
+		}
+	}
+	return s.Message, causes
+}
+
+// splitManifest splits a multi-document YAML stream into raw documents,
+// keeping empty documents so indexes match the position in the input
+func splitManifest(manifest []byte) ([][]byte, error) {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(manifest)))
+
+	var docs [][]byte
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest: %w", err)
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
+}
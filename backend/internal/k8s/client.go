@@ -5,8 +5,12 @@ import (
 	"os"
 	"path/filepath"
 
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
 	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
@@ -16,8 +20,13 @@ import (
 type Client struct {
 	Clientset     *kubernetes.Clientset
 	MetricsClient *metricsv.Clientset
+	DynamicClient dynamic.Interface
+	RESTMapper    meta.RESTMapper // kind to resource mapping, re-discovered when a kind is not found (new CRDs)
 	Config        *rest.Config
 	RawConfig     api.Config
+	Metrics       MetricsProvider // provider of the current context, see UsePrometheus
+
+	prometheus map[string]MetricsProvider // per context name
 }
 
 // NewClient creates a new Kubernetes client
@@ -39,17 +48,26 @@ func NewClient() (*Client, error) {
 		return nil, fmt.Errorf("failed to create metrics client: %w", err)
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
 	rawConfig, err := clientcmd.LoadFromFile(kubeconfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load raw config: %w", err)
 	}
 
-	return &Client{
+	c := &Client{
 		Clientset:     clientset,
 		MetricsClient: metricsClient,
+		DynamicClient: dynamicClient,
+		RESTMapper:    newRESTMapper(clientset),
 		Config:        config,
 		RawConfig:     *rawConfig,
-	}, nil
+	}
+	c.selectMetricsProvider()
+	return c, nil
 }
 
 // SwitchContext switches to a different Kubernetes context
@@ -82,10 +100,18 @@ func (c *Client) SwitchContext(contextName string) error {
 		return fmt.Errorf("failed to create metrics client: %w", err)
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
 	c.Clientset = clientset
 	c.MetricsClient = metricsClient
+	c.DynamicClient = dynamicClient
+	c.RESTMapper = newRESTMapper(clientset)
 	c.Config = config
 	c.RawConfig = *rawConfig
+	c.selectMetricsProvider()
 
 	return nil
 }
@@ -99,6 +125,24 @@ func (c *Client) GetContexts() ([]string, string) {
 	return contexts, c.RawConfig.CurrentContext
 }
 
+// ClusterKey identifies the cluster of the current context by its API server
+// URL, so contexts pointing at the same cluster share per-cluster state and
+// same-named contexts of different kubeconfigs don't. Falls back to the
+// context name when the cluster has no server.
+func (c *Client) ClusterKey() string {
+	if ctx, ok := c.RawConfig.Contexts[c.RawConfig.CurrentContext]; ok && ctx != nil {
+		if cluster, ok := c.RawConfig.Clusters[ctx.Cluster]; ok && cluster != nil && cluster.Server != "" {
+			return cluster.Server
+		}
+	}
+	return c.RawConfig.CurrentContext
+}
+
+// newRESTMapper creates a discovery-backed REST mapper with an in-memory cache
+func newRESTMapper(clientset *kubernetes.Clientset) meta.RESTMapper {
+	return restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientset.Discovery()))
+}
+
 func getKubeConfigPath() string {
 	if kubeconfigEnv := os.Getenv("KUBECONFIG"); kubeconfigEnv != "" {
 		return kubeconfigEnv
@@ -3,13 +3,33 @@ package k8s
 import (
 	"context"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/krzyzao/kub/internal/models"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// ConfigMapUpdate represents changes to the data keys of a ConfigMap
+type ConfigMapUpdate struct {
+	ResourceVersion string            // version the edit started from
+	Set             map[string]string // keys to add or replace
+	Remove          []string          // keys to delete
+}
+
+// ConfigMapConflictError is returned when a ConfigMap changed after the
+// version being edited
+type ConfigMapConflictError struct {
+	ResourceVersion string            // current version
+	Data            map[string]string // current data
+}
+
+func (e *ConfigMapConflictError) Error() string {
+	return fmt.Sprintf("configmap was modified (now at resourceVersion %s)", e.ResourceVersion)
+}
+
 // GetConfigMaps returns all configmaps in the given namespace
 func (c *Client) GetConfigMaps(ctx context.Context, namespace string) ([]models.ConfigMap, error) {
 	listOpts := metav1.ListOptions{}
@@ -46,6 +66,185 @@ func (c *Client) GetConfigMap(ctx context.Context, namespace, name string) (*mod
 	return &cm, nil
 }
 
+// UpdateConfigMapData changes data keys of a ConfigMap if it is still at
+// update.ResourceVersion, otherwise *ConfigMapConflictError is returned.
+// The per-key changes are returned for the edit history.
+func (c *Client) UpdateConfigMapData(ctx context.Context, namespace, name string, update ConfigMapUpdate) (*models.ConfigMap, []models.EditChange, error) {
+	current, err := c.Clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get configmap: %w", err)
+	}
+	if current.ResourceVersion != update.ResourceVersion {
+		return nil, nil, &ConfigMapConflictError{ResourceVersion: current.ResourceVersion, Data: current.Data}
+	}
+
+	before := current.Data
+	updated := current.DeepCopy()
+	if updated.Data == nil {
+		updated.Data = make(map[string]string)
+	}
+	for k, v := range update.Set {
+		updated.Data[k] = v
+	}
+	for _, k := range update.Remove {
+		delete(updated.Data, k)
+	}
+
+	// The API server rejects the update if the object changed since our Get
+	result, err := c.Clientset.CoreV1().ConfigMaps(namespace).Update(ctx, updated, metav1.UpdateOptions{})
+	if apierrors.IsConflict(err) {
+		latest, getErr := c.Clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+		if getErr != nil {
+			return nil, nil, fmt.Errorf("failed to get configmap: %w", getErr)
+		}
+		return nil, nil, &ConfigMapConflictError{ResourceVersion: latest.ResourceVersion, Data: latest.Data}
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update configmap: %w", err)
+	}
+
+	cm := convertConfigMap(*result)
+	return &cm, MapChanges("data", before, result.Data), nil
+}
+
+// ConfigMapConflictView builds the three-way view of the keys touched by an
+// update that hit a conflict. base holds the values the edit started from;
+// keys missing from it are treated as absent in the base version.
+func ConfigMapConflictView(base map[string]string, conflict *ConfigMapConflictError, update ConfigMapUpdate) models.ConfigMapConflict {
+	mine := make(map[string]*string)
+	for k, v := range update.Set {
+		v := v
+		mine[k] = &v
+	}
+	for _, k := range update.Remove {
+		mine[k] = nil
+	}
+
+	keys := make([]string, 0, len(mine))
+	for k := range mine {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	view := models.ConfigMapConflict{
+		ResourceVersion: conflict.ResourceVersion,
+		Keys:            make([]models.ConfigMapKeyConflict, 0, len(keys)),
+	}
+	for _, k := range keys {
+		kc := models.ConfigMapKeyConflict{
+			Key:    k,
+			Base:   lookup(base, k),
+			Theirs: lookup(conflict.Data, k),
+			Mine:   mine[k],
+		}
+		kc.Conflicting = !equalPtr(kc.Theirs, kc.Base) && !equalPtr(kc.Theirs, kc.Mine)
+		kc.TheirsDiff = unifiedDiff("base", "theirs", deref(kc.Base), deref(kc.Theirs))
+		kc.MineDiff = unifiedDiff("base", "mine", deref(kc.Base), deref(kc.Mine))
+		view.Keys = append(view.Keys, kc)
+	}
+
+	return view
+}
+
+// FindConfigMapConsumers returns the deployments whose pods reference a
+// ConfigMap through env, envFrom or volumes (including projected volumes)
+func (c *Client) FindConfigMapConsumers(ctx context.Context, namespace, name string) ([]string, error) {
+	deployments, err := c.Clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+
+	var consumers []string
+	for _, d := range deployments.Items {
+		if podSpecUsesConfigMap(&d.Spec.Template.Spec, name) {
+			consumers = append(consumers, d.Name)
+		}
+	}
+	sort.Strings(consumers)
+
+	return consumers, nil
+}
+
+func podSpecUsesConfigMap(spec *corev1.PodSpec, name string) bool {
+	for _, v := range spec.Volumes {
+		if v.ConfigMap != nil && v.ConfigMap.Name == name {
+			return true
+		}
+		if v.Projected != nil {
+			for _, src := range v.Projected.Sources {
+				if src.ConfigMap != nil && src.ConfigMap.Name == name {
+					return true
+				}
+			}
+		}
+	}
+
+	containers := append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, container := range containers {
+		for _, from := range container.EnvFrom {
+			if from.ConfigMapRef != nil && from.ConfigMapRef.Name == name {
+				return true
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil && env.ValueFrom.ConfigMapKeyRef.Name == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// MapChanges lists the keys that differ between two maps, sorted by key
+func MapChanges(field string, before, after map[string]string) []models.EditChange {
+	keys := make(map[string]bool)
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	changes := []models.EditChange{}
+	for _, k := range sorted {
+		oldValue, newValue := lookup(before, k), lookup(after, k)
+		if !equalPtr(oldValue, newValue) {
+			changes = append(changes, models.EditChange{Field: field, Key: k, Old: oldValue, New: newValue})
+		}
+	}
+	return changes
+}
+
+// lookup returns a pointer to a copy of m[k], or nil if the key is absent
+func lookup(m map[string]string, k string) *string {
+	v, ok := m[k]
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+func equalPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
 func convertConfigMap(cm corev1.ConfigMap) models.ConfigMap {
 	// Get data count and keys
 	dataCount := len(cm.Data) + len(cm.BinaryData)
@@ -67,15 +266,16 @@ func convertConfigMap(cm corev1.ConfigMap) models.ConfigMap {
 	age := formatDuration(time.Since(cm.CreationTimestamp.Time))
 
 	return models.ConfigMap{
-		Name:        cm.Name,
-		Namespace:   cm.Namespace,
-		DataCount:   dataCount,
-		Keys:        keys,
-		Age:         age,
-		CreatedAt:   cm.CreationTimestamp.Time,
-		Data:        cm.Data,
-		BinaryData:  binaryDataInfo,
-		Labels:      cm.Labels,
-		Annotations: cm.Annotations,
+		Name:            cm.Name,
+		Namespace:       cm.Namespace,
+		DataCount:       dataCount,
+		Keys:            keys,
+		Age:             age,
+		CreatedAt:       cm.CreationTimestamp.Time,
+		ResourceVersion: cm.ResourceVersion,
+		Data:            cm.Data,
+		BinaryData:      binaryDataInfo,
+		Labels:          cm.Labels,
+		Annotations:     cm.Annotations,
 	}
 }
@@ -0,0 +1,158 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/krzyzao/kub/internal/models"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+)
+
+// Annotation set by `kubectl create job --from=cronjob/...` on manually created jobs
+const instantiateAnnotation = "cronjob.kubernetes.io/instantiate"
+
+// GetCronJobs returns all cronjobs in the given namespace
+func (c *Client) GetCronJobs(ctx context.Context, namespace string) ([]models.CronJob, error) {
+	listOpts := metav1.ListOptions{}
+
+	var cronJobList *batchv1.CronJobList
+	var err error
+
+	if namespace == "" || namespace == "all" {
+		cronJobList, err = c.Clientset.BatchV1().CronJobs("").List(ctx, listOpts)
+	} else {
+		cronJobList, err = c.Clientset.BatchV1().CronJobs(namespace).List(ctx, listOpts)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cronjobs: %w", err)
+	}
+
+	cronJobs := make([]models.CronJob, 0, len(cronJobList.Items))
+	for _, cj := range cronJobList.Items {
+		cronJobs = append(cronJobs, convertCronJob(cj))
+	}
+
+	return cronJobs, nil
+}
+
+// SetCronJobSuspend suspends or resumes a cronjob
+func (c *Client) SetCronJobSuspend(ctx context.Context, namespace, name string, suspend bool) (*models.CronJob, error) {
+	patch := fmt.Sprintf(`{"spec":{"suspend":%t}}`, suspend)
+	cronJob, err := c.Clientset.BatchV1().CronJobs(namespace).Patch(ctx, name, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to patch cronjob: %w", err)
+	}
+
+	cj := convertCronJob(*cronJob)
+	return &cj, nil
+}
+
+// TriggerCronJob creates a one-off job from a cronjob's jobTemplate like
+// `kubectl create job --from=cronjob/<name>`. The job has no ownerReference,
+// so the cronjob's history limits never delete it.
+func (c *Client) TriggerCronJob(ctx context.Context, namespace, name string) (string, error) {
+	cronJob, err := c.Clientset.BatchV1().CronJobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get cronjob: %w", err)
+	}
+
+	// Job names are limited to 63 characters (they become a pod label value)
+	prefix := name
+	if len(prefix) > 63-len("-manual-xxxxx") {
+		// A cut can end in - or ., which must not run into the suffix
+		prefix = strings.TrimRight(prefix[:63-len("-manual-xxxxx")], "-.")
+	}
+
+	annotations := map[string]string{instantiateAnnotation: "manual"}
+	for k, v := range cronJob.Spec.JobTemplate.Annotations {
+		annotations[k] = v
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        prefix + "-manual-" + utilrand.String(5),
+			Namespace:   namespace,
+			Labels:      cronJob.Spec.JobTemplate.Labels,
+			Annotations: annotations,
+		},
+		Spec: *cronJob.Spec.JobTemplate.Spec.DeepCopy(),
+	}
+
+	created, err := c.Clientset.BatchV1().Jobs(namespace).Create(ctx, job, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to create job: %w", err)
+	}
+
+	return created.Name, nil
+}
+
+// GetJobPods returns the pods of a job, oldest first, and whether the job has finished
+func (c *Client) GetJobPods(ctx context.Context, namespace, name string) ([]corev1.Pod, bool, error) {
+	job, err := c.Clientset.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get job: %w", err)
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(job.Spec.Selector)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid job selector: %w", err)
+	}
+
+	podList, err := c.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	pods := podList.Items
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].CreationTimestamp.Before(&pods[j].CreationTimestamp)
+	})
+
+	return pods, isJobFinished(job), nil
+}
+
+func isJobFinished(job *batchv1.Job) bool {
+	for _, cond := range job.Status.Conditions {
+		if (cond.Type == batchv1.JobComplete || cond.Type == batchv1.JobFailed) && cond.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+func convertCronJob(cj batchv1.CronJob) models.CronJob {
+	cronJob := models.CronJob{
+		Name:      cj.Name,
+		Namespace: cj.Namespace,
+		Schedule:  cj.Spec.Schedule,
+		Suspend:   cj.Spec.Suspend != nil && *cj.Spec.Suspend,
+		Active:    len(cj.Status.Active),
+		Labels:    cj.Labels,
+		Age:       formatDuration(time.Since(cj.CreationTimestamp.Time)),
+		CreatedAt: cj.CreationTimestamp.Time,
+	}
+
+	if cj.Spec.TimeZone != nil {
+		cronJob.TimeZone = *cj.Spec.TimeZone
+	}
+	if cj.Status.LastScheduleTime != nil {
+		t := cj.Status.LastScheduleTime.Time
+		cronJob.LastScheduleTime = &t
+	}
+	if cj.Status.LastSuccessfulTime != nil {
+		t := cj.Status.LastSuccessfulTime.Time
+		cronJob.LastSuccessfulTime = &t
+	}
+
+	return cronJob
+}
@@ -0,0 +1,110 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+)
+
+// Waiting reasons after which an ephemeral container will not start on its own
+var debugContainerFailureReasons = map[string]bool{
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CreateContainerError":       true,
+	"CreateContainerConfigError": true,
+	"RunContainerError":          true,
+}
+
+// DebugOptions represents options for attaching an ephemeral debug container
+type DebugOptions struct {
+	Image           string
+	TargetContainer string   // container whose process namespace is shared, optional
+	Command         []string // defaults to the image entrypoint
+}
+
+// CreateDebugContainer adds an ephemeral debug container to a running pod and
+// returns its generated name
+func (c *Client) CreateDebugContainer(ctx context.Context, namespace, podName string, opts DebugOptions) (string, error) {
+	pod, err := c.Clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get pod: %w", err)
+	}
+
+	if pod.Status.Phase != corev1.PodRunning {
+		return "", fmt.Errorf("pod %s is not running (phase %s)", podName, pod.Status.Phase)
+	}
+
+	if opts.TargetContainer != "" {
+		found := false
+		for _, c := range pod.Spec.Containers {
+			if c.Name == opts.TargetContainer {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("target container %s not found", opts.TargetContainer)
+		}
+	}
+
+	name := "debugger-" + utilrand.String(5)
+
+	pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:                     name,
+			Image:                    opts.Image,
+			Command:                  opts.Command,
+			ImagePullPolicy:          corev1.PullIfNotPresent,
+			Stdin:                    true,
+			TTY:                      true,
+			TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+		},
+		TargetContainerName: opts.TargetContainer,
+	})
+
+	_, err = c.Clientset.CoreV1().Pods(namespace).UpdateEphemeralContainers(ctx, podName, pod, metav1.UpdateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to add ephemeral container: %w", err)
+	}
+
+	return name, nil
+}
+
+// WaitForEphemeralContainer blocks until the ephemeral container is running,
+// fails to start or the context expires
+func (c *Client) WaitForEphemeralContainer(ctx context.Context, namespace, podName, container string) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		pod, err := c.Clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get pod: %w", err)
+		}
+
+		for _, cs := range pod.Status.EphemeralContainerStatuses {
+			if cs.Name != container {
+				continue
+			}
+			switch {
+			case cs.State.Running != nil:
+				return nil
+			case cs.State.Terminated != nil:
+				return fmt.Errorf("debug container exited: %s", getContainerStateDetails(cs.State))
+			case cs.State.Waiting != nil && debugContainerFailureReasons[cs.State.Waiting.Reason]:
+				return fmt.Errorf("debug container failed to start: %s: %s", cs.State.Waiting.Reason, cs.State.Waiting.Message)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for debug container %s to start: %w", container, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package k8s
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"sigs.k8s.io/yaml"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// yamlDiff renders both objects as YAML and returns a unified diff between them
+func yamlDiff(fromName, toName string, from, to interface{}) (string, error) {
+	fromYAML, err := toYAML(from)
+	if err != nil {
+		return "", err
+	}
+	toYAMLStr, err := toYAML(to)
+	if err != nil {
+		return "", err
+	}
+	return unifiedDiff(fromName, toName, fromYAML, toYAMLStr), nil
+}
+
+func toYAML(obj interface{}) (string, error) {
+	if obj == nil {
+		return "", nil
+	}
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal yaml: %w", err)
+	}
+	return string(data), nil
+}
+
+// unifiedDiff returns a unified diff of two texts, or "" if they are equal.
+// The matcher works in linear space, so large manifests are safe to diff.
+func unifiedDiff(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        diffLines(from),
+		B:        diffLines(to),
+		FromFile: fromName,
+		ToFile:   toName,
+		Context:  diffContext,
+	})
+	if err != nil {
+		// Only returned by the writer, which is a strings.Builder
+		return ""
+	}
+	return diff
+}
+
+// diffLines splits s into lines that keep their newline, as difflib expects
+func diffLines(s string) []string {
+	lines := splitLines(s)
+	for i := range lines {
+		lines[i] += "\n"
+	}
+	return lines
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,121 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// ExecOptions represents options for running a command in a container
+type ExecOptions struct {
+	Container string
+	Command   []string
+	TTY       bool
+	Stdin     io.Reader
+	Stdout    io.Writer
+	Stderr    io.Writer // ignored when TTY is set, the terminal merges both streams
+	SizeQueue remotecommand.TerminalSizeQueue
+}
+
+// ResolveExecContainer returns the container an exec session should attach to,
+// defaulting like kubectl when no container is given
+func (c *Client) ResolveExecContainer(ctx context.Context, namespace, podName, container string) (string, error) {
+	pod, err := c.Clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get pod: %w", err)
+	}
+
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return "", fmt.Errorf("cannot exec into a container in a completed pod; current phase is %s", pod.Status.Phase)
+	}
+
+	return resolveContainer(pod, container)
+}
+
+// ExecInPod runs a command in a container, streaming stdin/stdout/stderr until it exits.
+// WebSocket is tried first with a fallback to SPDY for older API servers.
+func (c *Client) ExecInPod(ctx context.Context, namespace, podName string, opts ExecOptions) error {
+	execOpts := &corev1.PodExecOptions{
+		Container: opts.Container,
+		Command:   opts.Command,
+		Stdin:     opts.Stdin != nil,
+		Stdout:    opts.Stdout != nil,
+		Stderr:    opts.Stderr != nil && !opts.TTY,
+		TTY:       opts.TTY,
+	}
+
+	req := c.Clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(podName).
+		SubResource("exec").
+		VersionedParams(execOpts, scheme.ParameterCodec)
+
+	spdyExec, err := remotecommand.NewSPDYExecutor(c.Config, "POST", req.URL())
+	if err != nil {
+		return fmt.Errorf("failed to create spdy executor: %w", err)
+	}
+
+	wsExec, err := remotecommand.NewWebSocketExecutor(c.Config, "GET", req.URL().String())
+	if err != nil {
+		return fmt.Errorf("failed to create websocket executor: %w", err)
+	}
+
+	executor, err := remotecommand.NewFallbackExecutor(wsExec, spdyExec, func(err error) bool {
+		return httpstream.IsUpgradeFailure(err) || httpstream.IsHTTPSProxyError(err)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create executor: %w", err)
+	}
+
+	streamOpts := remotecommand.StreamOptions{
+		Stdin:             opts.Stdin,
+		Stdout:            opts.Stdout,
+		Tty:               opts.TTY,
+		TerminalSizeQueue: opts.SizeQueue,
+	}
+	if execOpts.Stderr {
+		streamOpts.Stderr = opts.Stderr
+	}
+
+	return executor.StreamWithContext(ctx, streamOpts)
+}
+
+// FindShell returns the first of the candidate shells that can be started in the container
+func (c *Client) FindShell(ctx context.Context, namespace, podName, container string, candidates []string) (string, error) {
+	for _, shell := range candidates {
+		err := c.ExecInPod(ctx, namespace, podName, ExecOptions{
+			Container: container,
+			Command:   []string{shell, "-c", "exit 0"},
+			Stdout:    io.Discard,
+			Stderr:    io.Discard,
+		})
+		if err == nil {
+			return shell, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+	}
+	return "", fmt.Errorf("none of %v could be started in container %s", candidates, container)
+}
+
+// ExitCode extracts the remote process exit code from an exec error
+func ExitCode(err error) (int, bool) {
+	if err == nil {
+		return 0, true
+	}
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) && exitErr.Exited() {
+		return exitErr.ExitStatus(), true
+	}
+	return 0, false
+}
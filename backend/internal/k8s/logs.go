@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -12,10 +13,12 @@ import (
 
 // LogOptions represents options for fetching logs
 type LogOptions struct {
-	Container  string
-	TailLines  int64
-	Previous   bool
-	Timestamps bool
+	Container    string
+	TailLines    int64
+	Previous     bool
+	Timestamps   bool
+	SinceTime    *time.Time // only logs at or after this time
+	SinceSeconds int64      // only logs newer than this many seconds; ignored if SinceTime is set
 }
 
 // GetPodLogs returns logs for a pod/container
@@ -25,36 +28,10 @@ func (c *Client) GetPodLogs(ctx context.Context, namespace, podName string, opts
 		return nil, fmt.Errorf("failed to get pod: %w", err)
 	}
 
-	// If no container specified, use the first one
-	container := opts.Container
-	if container == "" {
-		if len(pod.Spec.Containers) > 0 {
-			container = pod.Spec.Containers[0].Name
-		} else {
-			return nil, fmt.Errorf("no containers found in pod")
-		}
-	}
-
-	// Validate container exists
-	containerExists := false
-	for _, c := range pod.Spec.Containers {
-		if c.Name == container {
-			containerExists = true
-			break
-		}
-	}
-	if !containerExists {
-		return nil, fmt.Errorf("container %s not found", container)
-	}
-
-	// If using previous logs, check init containers too
-	if opts.Previous && containerExists {
-		for _, c := range pod.Spec.InitContainers {
-			if c.Name == container {
-				containerExists = true
-				break
-			}
-		}
+	// Accept init, regular and ephemeral containers, defaulting like kubectl
+	container, err := resolveContainer(pod, opts.Container)
+	if err != nil {
+		return nil, err
 	}
 
 	logOpts := corev1.PodLogOptions{
@@ -85,26 +62,10 @@ func (c *Client) GetPodLogsStream(ctx context.Context, namespace, podName string
 		return nil, fmt.Errorf("failed to get pod: %w", err)
 	}
 
-	// If no container specified, use the first one
-	container := opts.Container
-	if container == "" {
-		if len(pod.Spec.Containers) > 0 {
-			container = pod.Spec.Containers[0].Name
-		} else {
-			return nil, fmt.Errorf("no containers found in pod")
-		}
-	}
-
-	// Validate container exists
-	containerExists := false
-	for _, c := range pod.Spec.Containers {
-		if c.Name == container {
-			containerExists = true
-			break
-		}
-	}
-	if !containerExists {
-		return nil, fmt.Errorf("container %s not found", container)
+	// Accept init, regular and ephemeral containers, defaulting like kubectl
+	container, err := resolveContainer(pod, opts.Container)
+	if err != nil {
+		return nil, err
 	}
 
 	logOpts := corev1.PodLogOptions{
@@ -118,6 +79,10 @@ func (c *Client) GetPodLogsStream(ctx context.Context, namespace, podName string
 	if opts.TailLines > 0 {
 		logOpts.TailLines = &opts.TailLines
 	}
+	if opts.SinceTime != nil {
+		since := metav1.NewTime(*opts.SinceTime)
+		logOpts.SinceTime = &since
+	}
 
 	req := c.Clientset.CoreV1().Pods(namespace).GetLogs(podName, &logOpts)
 	stream, err := req.Stream(ctx)
@@ -128,6 +93,97 @@ func (c *Client) GetPodLogsStream(ctx context.Context, namespace, podName string
 	return stream, nil
 }
 
+// OpenPodLogs returns a non-following stream of a container's logs, so large
+// logs can be scanned without loading them into memory
+func (c *Client) OpenPodLogs(ctx context.Context, namespace, podName string, opts LogOptions) (io.ReadCloser, error) {
+	pod, err := c.Clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod: %w", err)
+	}
+
+	container, err := resolveContainer(pod, opts.Container)
+	if err != nil {
+		return nil, err
+	}
+
+	logOpts := corev1.PodLogOptions{
+		Container:  container,
+		Previous:   opts.Previous,
+		Timestamps: opts.Timestamps,
+	}
+	if opts.TailLines > 0 {
+		logOpts.TailLines = &opts.TailLines
+	}
+	if opts.SinceTime != nil {
+		since := metav1.NewTime(*opts.SinceTime)
+		logOpts.SinceTime = &since
+	} else if opts.SinceSeconds > 0 {
+		logOpts.SinceSeconds = &opts.SinceSeconds
+	}
+
+	stream, err := c.Clientset.CoreV1().Pods(namespace).GetLogs(podName, &logOpts).Stream(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs: %w", err)
+	}
+
+	return stream, nil
+}
+
+// GetContainerStatus returns the status of a container (nil until the kubelet
+// reports it) and whether the container is terminated for good, i.e. it will
+// not be started again
+func (c *Client) GetContainerStatus(ctx context.Context, namespace, podName, container string) (*corev1.ContainerStatus, bool, error) {
+	pod, err := c.Clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get pod: %w", err)
+	}
+
+	container, err = resolveContainer(pod, container)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, status := range podContainerStatuses(pod) {
+		if status.Name == container {
+			return &status, containerFinished(pod, &status), nil
+		}
+	}
+	return nil, pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed, nil
+}
+
+// containerFinished reports whether a terminated container will stay
+// terminated, from the pod phase, the kind of container and the restart policy
+func containerFinished(pod *corev1.Pod, status *corev1.ContainerStatus) bool {
+	terminated := status.State.Terminated
+	if terminated == nil {
+		return false
+	}
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return true
+	}
+
+	for _, c := range pod.Spec.InitContainers {
+		if c.Name != status.Name {
+			continue
+		}
+		if c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			return false // sidecars restart for as long as the pod runs
+		}
+		// A completed init container is done; a failed one is retried unless
+		// the pod never restarts
+		return terminated.ExitCode == 0 || pod.Spec.RestartPolicy == corev1.RestartPolicyNever
+	}
+
+	switch pod.Spec.RestartPolicy {
+	case corev1.RestartPolicyNever:
+		return true
+	case corev1.RestartPolicyOnFailure:
+		return terminated.ExitCode == 0
+	default:
+		return false
+	}
+}
+
 // GetContainerNames returns list of container names for a pod
 func (c *Client) GetContainerNames(ctx context.Context, namespace, podName string) ([]string, error) {
 	pod, err := c.Clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
@@ -157,3 +213,38 @@ func (c *Client) GetContainerNames(ctx context.Context, namespace, podName strin
 
 	return containers, nil
 }
+
+// defaultContainerAnnotation selects the container kubectl uses when none is given
+const defaultContainerAnnotation = "kubectl.kubernetes.io/default-container"
+
+// resolveContainer validates a container name against the same set GetContainerNames
+// returns (init, regular and ephemeral). An empty name selects the default container.
+func resolveContainer(pod *corev1.Pod, container string) (string, error) {
+	if container == "" {
+		if name := pod.Annotations[defaultContainerAnnotation]; name != "" {
+			container = name
+		} else if len(pod.Spec.Containers) > 0 {
+			return pod.Spec.Containers[0].Name, nil
+		} else {
+			return "", fmt.Errorf("no containers found in pod")
+		}
+	}
+
+	for _, c := range pod.Spec.InitContainers {
+		if c.Name == container {
+			return container, nil
+		}
+	}
+	for _, c := range pod.Spec.Containers {
+		if c.Name == container {
+			return container, nil
+		}
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		if c.Name == container {
+			return container, nil
+		}
+	}
+
+	return "", fmt.Errorf("container %s not found", container)
+}
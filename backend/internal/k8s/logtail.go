@@ -0,0 +1,344 @@
+package k8s
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/krzyzao/kub/internal/telemetry"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// DefaultMaxLogStreams caps concurrent container log streams of one tailer
+const DefaultMaxLogStreams = 50
+
+// LogTailOptions represents options for tailing the logs of many pods
+type LogTailOptions struct {
+	LabelSelector string
+	Container     string // only containers with this name; empty = every container
+	TailLines     int64  // lines to fetch when first attaching to a container; 0 = all
+	Timestamps    bool
+	MaxStreams    int // 0 = DefaultMaxLogStreams
+}
+
+// LogLine represents one line of an aggregated log stream
+type LogLine struct {
+	Pod       string
+	Container string
+	Text      string // without the trailing newline
+}
+
+// LogTailEvent reports a stream being attached, detached or not attached
+// because the stream limit was reached
+type LogTailEvent struct {
+	Type      string // attach, detach, limit
+	Pod       string
+	Container string
+	Message   string
+}
+
+// LogTailer follows the logs of every container of every pod matching a label
+// selector. Pods that appear later are attached and deleted pods are detached;
+// a restarted container is attached again.
+type LogTailer struct {
+	client    *Client
+	namespace string
+	opts      LogTailOptions
+	lines     chan<- LogLine
+	events    func(LogTailEvent)
+
+	mu      sync.Mutex
+	ctx     context.Context
+	pods    map[string]*corev1.Pod
+	streams map[string]*tailStream // pod/container
+	active  int
+	limited map[string]bool
+	wg      sync.WaitGroup
+}
+
+type tailStream struct {
+	pod          string
+	cancel       context.CancelFunc
+	restartCount int32 // container instance being (or last) streamed
+	active       bool
+}
+
+// NewLogTailer creates a tailer sending lines to lines. events may be nil and
+// must not block; it is called with the tailer's lock held.
+func (c *Client) NewLogTailer(namespace string, opts LogTailOptions, lines chan<- LogLine, events func(LogTailEvent)) *LogTailer {
+	if opts.MaxStreams <= 0 {
+		opts.MaxStreams = DefaultMaxLogStreams
+	}
+	if events == nil {
+		events = func(LogTailEvent) {}
+	}
+
+	return &LogTailer{
+		client:    c,
+		namespace: namespace,
+		opts:      opts,
+		lines:     lines,
+		events:    events,
+		pods:      make(map[string]*corev1.Pod),
+		streams:   make(map[string]*tailStream),
+		limited:   make(map[string]bool),
+	}
+}
+
+// Run tails logs until ctx is cancelled or listing pods fails. It waits for
+// every stream to stop before returning.
+func (t *LogTailer) Run(ctx context.Context) error {
+	defer t.wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	t.mu.Lock()
+	t.ctx = ctx
+	t.mu.Unlock()
+
+	pods := t.client.Clientset.CoreV1().Pods(t.namespace)
+	listOpts := metav1.ListOptions{LabelSelector: t.opts.LabelSelector}
+
+	for {
+		podList, err := pods.List(ctx, listOpts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to list pods: %w", err)
+		}
+
+		t.mu.Lock()
+		t.pods = make(map[string]*corev1.Pod, len(podList.Items))
+		for i := range podList.Items {
+			t.pods[podList.Items[i].Name] = &podList.Items[i]
+		}
+		t.reconcile()
+		t.mu.Unlock()
+
+		watchOpts := listOpts
+		watchOpts.ResourceVersion = podList.ResourceVersion
+		watcher, err := pods.Watch(ctx, watchOpts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to watch pods: %w", err)
+		}
+
+		t.consume(watcher)
+		watcher.Stop()
+
+		if ctx.Err() != nil {
+			return nil
+		}
+		// The watch expired or failed: list again to resync
+		telemetry.WatchRestarts.WithLabelValues("logtail").Inc()
+	}
+}
+
+// consume applies watch events until the watch ends or reports an error
+func (t *LogTailer) consume(watcher watch.Interface) {
+	for event := range watcher.ResultChan() {
+		pod, ok := event.Object.(*corev1.Pod)
+		if !ok {
+			return // watch error, e.g. resource version too old
+		}
+
+		t.mu.Lock()
+		switch event.Type {
+		case watch.Added, watch.Modified:
+			t.pods[pod.Name] = pod
+		case watch.Deleted:
+			delete(t.pods, pod.Name)
+		}
+		t.reconcile()
+		t.mu.Unlock()
+	}
+}
+
+// reconcile attaches streams for started containers and detaches streams of
+// pods that are gone. Must be called with t.mu held.
+func (t *LogTailer) reconcile() {
+	for key, s := range t.streams {
+		if _, ok := t.pods[s.pod]; !ok {
+			s.cancel()
+			delete(t.streams, key)
+			delete(t.limited, key)
+			t.events(LogTailEvent{Type: "detach", Pod: s.pod, Container: strings.TrimPrefix(key, s.pod+"/"), Message: "pod deleted"})
+		}
+	}
+	for key := range t.limited {
+		pod, _, _ := strings.Cut(key, "/")
+		if _, ok := t.pods[pod]; !ok {
+			delete(t.limited, key)
+		}
+	}
+
+	for _, pod := range t.pods {
+		for _, status := range podContainerStatuses(pod) {
+			if t.opts.Container != "" && status.Name != t.opts.Container {
+				continue
+			}
+			// Logs exist once a container has started
+			if status.State.Running == nil && status.State.Terminated == nil {
+				continue
+			}
+
+			key := pod.Name + "/" + status.Name
+			s := t.streams[key]
+			if s != nil && (s.active || s.restartCount >= status.RestartCount) {
+				continue
+			}
+
+			if t.active >= t.opts.MaxStreams {
+				if !t.limited[key] {
+					t.limited[key] = true
+					t.events(LogTailEvent{Type: "limit", Pod: pod.Name, Container: status.Name,
+						Message: fmt.Sprintf("stream limit of %d reached", t.opts.MaxStreams)})
+				}
+				continue
+			}
+
+			// Only the first instance starts at the tail; later instances are read whole
+			tailLines := t.opts.TailLines
+			if s != nil {
+				tailLines = 0
+			}
+			t.attach(key, pod.Name, status.Name, status.RestartCount, tailLines)
+		}
+	}
+}
+
+// attach starts streaming one container. Must be called with t.mu held.
+func (t *LogTailer) attach(key, pod, container string, restartCount int32, tailLines int64) {
+	ctx, cancel := context.WithCancel(t.ctx)
+	s := &tailStream{pod: pod, cancel: cancel, restartCount: restartCount, active: true}
+	t.streams[key] = s
+	t.active++
+	delete(t.limited, key)
+	t.events(LogTailEvent{Type: "attach", Pod: pod, Container: container})
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		defer cancel()
+
+		err := t.follow(ctx, pod, container, tailLines)
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.active--
+		s.active = false
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			t.events(LogTailEvent{Type: "detach", Pod: pod, Container: container, Message: err.Error()})
+		}
+		// A slot became free for containers skipped due to the limit
+		t.reconcile()
+	}()
+}
+
+func (t *LogTailer) follow(ctx context.Context, pod, container string, tailLines int64) error {
+	logOpts := &corev1.PodLogOptions{
+		Container:  container,
+		Follow:     true,
+		Timestamps: t.opts.Timestamps,
+	}
+	if tailLines > 0 {
+		logOpts.TailLines = &tailLines
+	}
+
+	stream, err := t.client.Clientset.CoreV1().Pods(t.namespace).GetLogs(pod, logOpts).Stream(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get log stream: %w", err)
+	}
+	defer stream.Close()
+
+	reader := bufio.NewReader(stream)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			select {
+			case t.lines <- LogLine{Pod: pod, Container: container, Text: strings.TrimRight(line, "\r\n")}:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("log stream interrupted: %w", err)
+		}
+	}
+}
+
+// podContainerStatuses returns the statuses of init, regular and ephemeral containers
+func podContainerStatuses(pod *corev1.Pod) []corev1.ContainerStatus {
+	statuses := make([]corev1.ContainerStatus, 0, len(pod.Status.InitContainerStatuses)+len(pod.Status.ContainerStatuses)+len(pod.Status.EphemeralContainerStatuses))
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	statuses = append(statuses, pod.Status.EphemeralContainerStatuses...)
+	return statuses
+}
+
+// WorkloadSelector returns the pod label selector of a Deployment, StatefulSet,
+// DaemonSet, ReplicaSet or Job
+func (c *Client) WorkloadSelector(ctx context.Context, namespace, kind, name string) (string, error) {
+	var selector *metav1.LabelSelector
+
+	switch strings.ToLower(kind) {
+	case "deployment":
+		d, err := c.Clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get deployment: %w", err)
+		}
+		selector = d.Spec.Selector
+	case "statefulset":
+		s, err := c.Clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get statefulset: %w", err)
+		}
+		selector = s.Spec.Selector
+	case "daemonset":
+		d, err := c.Clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get daemonset: %w", err)
+		}
+		selector = d.Spec.Selector
+	case "replicaset":
+		rs, err := c.Clientset.AppsV1().ReplicaSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get replicaset: %w", err)
+		}
+		selector = rs.Spec.Selector
+	case "job":
+		j, err := c.Clientset.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get job: %w", err)
+		}
+		selector = j.Spec.Selector
+	default:
+		return "", fmt.Errorf("unsupported workload kind %q", kind)
+	}
+
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return "", fmt.Errorf("invalid selector: %w", err)
+	}
+	if s.Empty() {
+		return "", fmt.Errorf("%s %s has an empty selector", kind, name)
+	}
+	return s.String(), nil
+}
@@ -0,0 +1,135 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/krzyzao/kub/internal/models"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Same limit the API server enforces on the total size of annotations
+const maxAnnotationsSize = 256 * 1024
+
+// MetadataPatch represents label and annotation changes. A nil value removes the key.
+type MetadataPatch struct {
+	Labels      map[string]*string `json:"labels,omitempty"`
+	Annotations map[string]*string `json:"annotations,omitempty"`
+}
+
+// Empty reports whether the patch changes nothing
+func (p MetadataPatch) Empty() bool {
+	return len(p.Labels) == 0 && len(p.Annotations) == 0
+}
+
+// ValidateMetadataPatch checks keys and values against the Kubernetes syntax
+// rules so invalid patches are rejected with a readable message
+func ValidateMetadataPatch(p MetadataPatch) error {
+	for k, v := range p.Labels {
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return fmt.Errorf("invalid label key %q: %s", k, strings.Join(errs, "; "))
+		}
+		if v == nil {
+			continue
+		}
+		if errs := validation.IsValidLabelValue(*v); len(errs) > 0 {
+			return fmt.Errorf("invalid value for label %q: %s", k, strings.Join(errs, "; "))
+		}
+	}
+
+	size := 0
+	for k, v := range p.Annotations {
+		if errs := validation.IsQualifiedName(strings.ToLower(k)); len(errs) > 0 {
+			return fmt.Errorf("invalid annotation key %q: %s", k, strings.Join(errs, "; "))
+		}
+		if v != nil {
+			size += len(k) + len(*v)
+		}
+	}
+	if size > maxAnnotationsSize {
+		return fmt.Errorf("annotations are too long: %d bytes, must be at most %d", size, maxAnnotationsSize)
+	}
+
+	return nil
+}
+
+// PatchMetadata applies label and annotation changes to any object with a JSON
+// merge patch and returns the new resourceVersion and the changes made
+func (c *Client) PatchMetadata(ctx context.Context, group, version, kind, namespace, name string, patch MetadataPatch) (string, []models.EditChange, error) {
+	resource, err := c.objectResource(group, version, kind, namespace)
+	if err != nil {
+		return "", nil, err
+	}
+
+	before, err := resource.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get %s: %w", kind, err)
+	}
+
+	// Merge patch semantics: null deletes a key, anything else sets it
+	data, err := json.Marshal(map[string]interface{}{"metadata": patch})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to build patch: %w", err)
+	}
+
+	after, err := resource.Patch(ctx, name, types.MergePatchType, data, metav1.PatchOptions{FieldManager: FieldManager})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to patch %s: %w", kind, err)
+	}
+
+	changes := append(
+		MapChanges("labels", before.GetLabels(), after.GetLabels()),
+		MapChanges("annotations", before.GetAnnotations(), after.GetAnnotations())...,
+	)
+	return after.GetResourceVersion(), changes, nil
+}
+
+// ListObjectNames returns the names of objects of a kind matching a label selector, sorted
+func (c *Client) ListObjectNames(ctx context.Context, group, version, kind, namespace, labelSelector string) ([]string, error) {
+	resource, err := c.objectResource(group, version, kind, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := resource.List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", kind, err)
+	}
+
+	names := make([]string, 0, len(list.Items))
+	for _, item := range list.Items {
+		names = append(names, item.GetName())
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// HistoryKind returns the kind name used for an object in the edit history
+// ("Deployment.apps", or just "ConfigMap" for the core group)
+func HistoryKind(group, kind string) string {
+	return groupVersionKind(group, "", kind).GroupKind().String()
+}
+
+// RevertMetadataPatch builds the patch that undoes the label and annotation
+// changes of an edit. Other fields (e.g. ConfigMap data) are not reverted.
+func RevertMetadataPatch(changes []models.EditChange) MetadataPatch {
+	patch := MetadataPatch{
+		Labels:      make(map[string]*string),
+		Annotations: make(map[string]*string),
+	}
+	for _, ch := range changes {
+		switch ch.Field {
+		case "labels":
+			patch.Labels[ch.Key] = ch.Old
+		case "annotations":
+			patch.Annotations[ch.Key] = ch.Old
+		}
+	}
+	return patch
+}
@@ -3,16 +3,19 @@ package k8s
 import (
 	"context"
 	"fmt"
+	"log"
+	"sort"
 
 	"github.com/krzyzao/kub/internal/models"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // GetNodeMetrics returns metrics for all nodes
 func (c *Client) GetNodeMetrics(ctx context.Context) ([]models.NodeMetrics, error) {
-	nodeMetrics, err := c.MetricsClient.MetricsV1beta1().NodeMetricses().List(ctx, metav1.ListOptions{})
+	usage, err := c.Metrics.NodeUsage(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get node metrics: %w", err)
+		return nil, err
 	}
 
 	// Get node capacities for percentage calculation
@@ -26,25 +29,22 @@ func (c *Client) GetNodeMetrics(ctx context.Context) ([]models.NodeMetrics, erro
 		nodeCapacities[n.Name] = n
 	}
 
-	metrics := make([]models.NodeMetrics, 0, len(nodeMetrics.Items))
-	for _, nm := range nodeMetrics.Items {
-		cpuUsage := nm.Usage.Cpu().MilliValue()
-		memUsage := nm.Usage.Memory().Value()
-
+	metrics := make([]models.NodeMetrics, 0, len(usage))
+	for _, nu := range usage {
 		var cpuPercent, memPercent float64
-		if node, ok := nodeCapacities[nm.Name]; ok {
+		if node, ok := nodeCapacities[nu.Name]; ok {
 			if node.CPUCapacity > 0 {
-				cpuPercent = float64(cpuUsage) / float64(node.CPUCapacity) * 100
+				cpuPercent = float64(nu.CPU) / float64(node.CPUCapacity) * 100
 			}
 			if node.MemoryCapacity > 0 {
-				memPercent = float64(memUsage) / float64(node.MemoryCapacity) * 100
+				memPercent = float64(nu.Memory) / float64(node.MemoryCapacity) * 100
 			}
 		}
 
 		metrics = append(metrics, models.NodeMetrics{
-			Name:        nm.Name,
-			CPUUsage:    cpuUsage,
-			MemoryUsage: memUsage,
+			Name:        nu.Name,
+			CPUUsage:    nu.CPU,
+			MemoryUsage: nu.Memory,
 			CPUPercent:  cpuPercent,
 			MemPercent:  memPercent,
 		})
@@ -53,61 +53,125 @@ func (c *Client) GetNodeMetrics(ctx context.Context) ([]models.NodeMetrics, erro
 	return metrics, nil
 }
 
-// GetPodMetrics returns metrics for all pods in the given namespace
-func (c *Client) GetPodMetrics(ctx context.Context, namespace string) ([]models.PodMetrics, error) {
-	var podMetricsList interface{ Items() []interface{} }
-	var err error
-
-	if namespace == "" || namespace == "all" {
-		pm, e := c.MetricsClient.MetricsV1beta1().PodMetricses("").List(ctx, metav1.ListOptions{})
-		if e != nil {
-			return nil, fmt.Errorf("failed to get pod metrics: %w", e)
+// ContainerResources holds the requests and limits of running containers,
+// by namespace/pod and container name. Entries are replaced, never
+// modified, so copies of the outer map can be read concurrently.
+type ContainerResources map[string]map[string]corev1.ResourceRequirements
+
+// SetPod records the app and sidecar containers of pod. Regular init
+// containers are skipped, since they are done before the others start.
+func (r ContainerResources) SetPod(pod *corev1.Pod) {
+	containers := make(map[string]corev1.ResourceRequirements, len(pod.Spec.Containers))
+	for _, c := range pod.Spec.InitContainers {
+		if isSidecar(c) {
+			containers[c.Name] = c.Resources
 		}
+	}
+	for _, c := range pod.Spec.Containers {
+		containers[c.Name] = c.Resources
+	}
+	r[pod.Namespace+"/"+pod.Name] = containers
+}
 
-		metrics := make([]models.PodMetrics, 0, len(pm.Items))
-		for _, p := range pm.Items {
-			var cpuTotal int64
-			var memTotal int64
-			for _, container := range p.Containers {
-				cpuTotal += container.Usage.Cpu().MilliValue()
-				memTotal += container.Usage.Memory().Value()
-			}
-			metrics = append(metrics, models.PodMetrics{
-				Name:        p.Name,
-				Namespace:   p.Namespace,
-				CPUUsage:    cpuTotal,
-				MemoryUsage: memTotal,
-			})
-		}
-		return metrics, nil
+// DeletePod forgets the containers of a pod
+func (r ContainerResources) DeletePod(namespace, name string) {
+	delete(r, namespace+"/"+name)
+}
+
+// isSidecar reports whether an init container keeps running next to the
+// app containers
+func isSidecar(c corev1.Container) bool {
+	return c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways
+}
+
+// GetContainerResources lists the pods in namespace for their container
+// requests and limits
+func (c *Client) GetContainerResources(ctx context.Context, namespace string) (ContainerResources, error) {
+	if namespace == "all" {
+		namespace = ""
 	}
 
-	pm, err := c.MetricsClient.MetricsV1beta1().PodMetricses(namespace).List(ctx, metav1.ListOptions{})
+	pods, err := c.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get pod metrics: %w", err)
+		return nil, fmt.Errorf("failed to list pods: %w", err)
 	}
 
-	_ = podMetricsList // silence unused warning
+	resources := make(ContainerResources, len(pods.Items))
+	for i := range pods.Items {
+		resources.SetPod(&pods.Items[i])
+	}
+	return resources, nil
+}
 
-	metrics := make([]models.PodMetrics, 0, len(pm.Items))
-	for _, p := range pm.Items {
+// GetPodMetrics returns metrics for all pods in the given namespace, with
+// the usage of each container next to its requests and limits
+func (c *Client) GetPodMetrics(ctx context.Context, namespace string) ([]models.PodMetrics, error) {
+	// Usage is still returned without requests and limits if pods can't be
+	// listed
+	resources, err := c.GetContainerResources(ctx, namespace)
+	if err != nil {
+		log.Printf("Failed to get pods for container metrics: %v", err)
+	}
+	return c.GetPodMetricsWithResources(ctx, namespace, resources)
+}
+
+// GetPodMetricsWithResources is GetPodMetrics with container resources the
+// caller already has, e.g. from a pod watch
+func (c *Client) GetPodMetricsWithResources(ctx context.Context, namespace string, resources ContainerResources) ([]models.PodMetrics, error) {
+	usage, err := c.Metrics.PodUsage(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make([]models.PodMetrics, 0, len(usage))
+	for _, p := range usage {
 		var cpuTotal int64
 		var memTotal int64
+		specs := resources[p.Namespace+"/"+p.Name]
+		containers := make([]models.ContainerMetrics, 0, len(p.Containers))
 		for _, container := range p.Containers {
-			cpuTotal += container.Usage.Cpu().MilliValue()
-			memTotal += container.Usage.Memory().Value()
+			cpuTotal += container.CPU
+			memTotal += container.Memory
+
+			cm := models.ContainerMetrics{
+				Name:        container.Name,
+				CPUUsage:    container.CPU,
+				MemoryUsage: container.Memory,
+			}
+			if spec, ok := specs[container.Name]; ok {
+				cm.CPURequest = spec.Requests.Cpu().MilliValue()
+				cm.CPULimit = spec.Limits.Cpu().MilliValue()
+				cm.MemoryRequest = spec.Requests.Memory().Value()
+				cm.MemoryLimit = spec.Limits.Memory().Value()
+				cm.CPURequestPercent = percentOf(cm.CPUUsage, cm.CPURequest)
+				cm.CPULimitPercent = percentOf(cm.CPUUsage, cm.CPULimit)
+				cm.MemRequestPercent = percentOf(cm.MemoryUsage, cm.MemoryRequest)
+				cm.MemLimitPercent = percentOf(cm.MemoryUsage, cm.MemoryLimit)
+			}
+			containers = append(containers, cm)
 		}
+		sort.Slice(containers, func(i, j int) bool { return containers[i].Name < containers[j].Name })
+
 		metrics = append(metrics, models.PodMetrics{
 			Name:        p.Name,
 			Namespace:   p.Namespace,
 			CPUUsage:    cpuTotal,
 			MemoryUsage: memTotal,
+			Containers:  containers,
 		})
 	}
 
 	return metrics, nil
 }
 
+// percentOf returns usage as a percentage of total, 0 when total is unset
+func percentOf(usage, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(usage) / float64(total) * 100
+}
+
 // GetClusterSummary returns a summary of the cluster state
 func (c *Client) GetClusterSummary(ctx context.Context, namespace string) (*models.ClusterSummary, error) {
 	// Get nodes
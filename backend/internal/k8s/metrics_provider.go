@@ -0,0 +1,155 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/krzyzao/kub/internal/models"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MetricsProvider is a source of current node and container resource usage
+type MetricsProvider interface {
+	// Name identifies the provider in logs and API responses
+	Name() string
+	NodeUsage(ctx context.Context) ([]NodeUsage, error)
+	// PodUsage returns the usage of the pods in namespace ("" or "all" = every namespace)
+	PodUsage(ctx context.Context, namespace string) ([]PodUsage, error)
+}
+
+// HistoryProvider is a MetricsProvider that keeps its own history
+type HistoryProvider interface {
+	MetricsProvider
+	UsageRange(ctx context.Context, q UsageRangeQuery) ([]models.MetricsSeries, error)
+}
+
+// IOProvider is a MetricsProvider that also knows network and disk throughput
+type IOProvider interface {
+	MetricsProvider
+	PodIO(ctx context.Context, namespace, pod string) ([]models.PodIO, error)
+}
+
+// NodeUsage is the usage of one node
+type NodeUsage struct {
+	Name   string
+	CPU    int64 // millicores
+	Memory int64 // bytes
+}
+
+// PodUsage is the usage of one pod, per container
+type PodUsage struct {
+	Name       string
+	Namespace  string
+	Containers []ContainerUsage
+}
+
+// ContainerUsage is the usage of one container
+type ContainerUsage struct {
+	Name   string
+	CPU    int64 // millicores
+	Memory int64 // bytes
+}
+
+// UsageRangeQuery selects the series returned by HistoryProvider.UsageRange
+type UsageRangeQuery struct {
+	Kind      string // node, pod or namespace; empty = every kind
+	Namespace string // restricts pods and namespaces; empty = all
+	Name      string // empty = every series of the kind
+	From      time.Time
+	To        time.Time
+	Step      time.Duration
+	Max       bool // also fill the CPUMax and MemoryMax of points
+}
+
+// UsePrometheus creates a Prometheus provider for every context cfg has a
+// URL for and selects the one of the current context. The provider is
+// re-selected on context switches; other contexts use metrics-server.
+func (c *Client) UsePrometheus(cfg PrometheusConfig) error {
+	providers := make(map[string]MetricsProvider, len(cfg.URLs))
+	for contextName, u := range cfg.URLs {
+		if contextName == "" {
+			contextName = c.RawConfig.CurrentContext
+		}
+		if _, exists := c.RawConfig.Contexts[contextName]; !exists {
+			return fmt.Errorf("context %s does not exist", contextName)
+		}
+		if _, exists := providers[contextName]; exists {
+			return fmt.Errorf("more than one Prometheus URL for context %s", contextName)
+		}
+		p, err := NewPrometheusProvider(u, cfg)
+		if err != nil {
+			return err
+		}
+		providers[contextName] = p
+	}
+
+	c.prometheus = providers
+	c.selectMetricsProvider()
+	return nil
+}
+
+// selectMetricsProvider sets Metrics for the current context
+func (c *Client) selectMetricsProvider() {
+	if p, ok := c.prometheus[c.RawConfig.CurrentContext]; ok {
+		c.Metrics = p
+		return
+	}
+	c.Metrics = &metricsServerProvider{client: c}
+}
+
+// metricsServerProvider reads the metrics.k8s.io API of metrics-server. It
+// has no history and no I/O metrics.
+type metricsServerProvider struct {
+	client *Client // MetricsClient changes on context switches
+}
+
+func (p *metricsServerProvider) Name() string {
+	return "metrics-server"
+}
+
+func (p *metricsServerProvider) NodeUsage(ctx context.Context) ([]NodeUsage, error) {
+	nodeMetrics, err := p.client.MetricsClient.MetricsV1beta1().NodeMetricses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node metrics: %w", err)
+	}
+
+	usage := make([]NodeUsage, 0, len(nodeMetrics.Items))
+	for _, nm := range nodeMetrics.Items {
+		usage = append(usage, NodeUsage{
+			Name:   nm.Name,
+			CPU:    nm.Usage.Cpu().MilliValue(),
+			Memory: nm.Usage.Memory().Value(),
+		})
+	}
+	return usage, nil
+}
+
+func (p *metricsServerProvider) PodUsage(ctx context.Context, namespace string) ([]PodUsage, error) {
+	if namespace == "all" {
+		namespace = ""
+	}
+
+	pm, err := p.client.MetricsClient.MetricsV1beta1().PodMetricses(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod metrics: %w", err)
+	}
+
+	usage := make([]PodUsage, 0, len(pm.Items))
+	for _, item := range pm.Items {
+		pod := PodUsage{
+			Name:       item.Name,
+			Namespace:  item.Namespace,
+			Containers: make([]ContainerUsage, 0, len(item.Containers)),
+		}
+		for _, container := range item.Containers {
+			pod.Containers = append(pod.Containers, ContainerUsage{
+				Name:   container.Name,
+				CPU:    container.Usage.Cpu().MilliValue(),
+				Memory: container.Usage.Memory().Value(),
+			})
+		}
+		usage = append(usage, pod)
+	}
+	return usage, nil
+}
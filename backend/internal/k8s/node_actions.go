@@ -0,0 +1,185 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/krzyzao/kub/internal/models"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	mirrorPodAnnotation = "kubernetes.io/config.mirror"
+	// How often a PDB-blocked eviction is retried during a drain
+	evictionRetryInterval = 5 * time.Second
+)
+
+// DrainOptions represents options for draining a node
+type DrainOptions struct {
+	Timeout            time.Duration
+	GracePeriodSeconds *int64 // nil = each pod's own terminationGracePeriodSeconds
+	DeleteEmptyDirData bool   // evict pods using emptyDir volumes (their data is lost)
+	Force              bool   // evict pods not managed by a controller
+}
+
+// CordonNode marks a node as unschedulable (or schedulable again when cordon is false)
+func (c *Client) CordonNode(ctx context.Context, name string, cordon bool) error {
+	patch := fmt.Sprintf(`{"spec":{"unschedulable":%t}}`, cordon)
+	_, err := c.Clientset.CoreV1().Nodes().Patch(ctx, name, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to patch node: %w", err)
+	}
+	return nil
+}
+
+// DrainNode cordons a node and evicts its pods like `kubectl drain --ignore-daemonsets`.
+// Every step is reported through progress. If any pod cannot be drained with the
+// given options, the blockers are reported and nothing is evicted.
+func (c *Client) DrainNode(ctx context.Context, name string, opts DrainOptions, progress func(models.DrainEvent)) error {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	if err := c.CordonNode(ctx, name, true); err != nil {
+		return err
+	}
+	progress(models.DrainEvent{Phase: "cordoned", Message: fmt.Sprintf("node %s cordoned", name)})
+
+	podList, err := c.Clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: "spec.nodeName=" + name,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	var toEvict []corev1.Pod
+	blocked := false
+	for _, pod := range podList.Items {
+		skip, blocker := classifyDrainPod(pod, opts)
+		ref := pod.Namespace + "/" + pod.Name
+		switch {
+		case skip != "":
+			progress(models.DrainEvent{Pod: ref, Phase: "skipped", Message: skip})
+		case blocker != "":
+			blocked = true
+			progress(models.DrainEvent{Pod: ref, Phase: "blocker", Message: blocker})
+		default:
+			toEvict = append(toEvict, pod)
+		}
+	}
+
+	if blocked {
+		return fmt.Errorf("cannot drain node %s: some pods cannot be evicted with the given options", name)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failed []string
+
+	for _, pod := range toEvict {
+		wg.Add(1)
+		go func(pod corev1.Pod) {
+			defer wg.Done()
+			if err := c.evictAndWait(ctx, pod, opts.GracePeriodSeconds, progress); err != nil {
+				progress(models.DrainEvent{Pod: pod.Namespace + "/" + pod.Name, Phase: "failed", Message: err.Error()})
+				mu.Lock()
+				failed = append(failed, pod.Namespace+"/"+pod.Name)
+				mu.Unlock()
+			}
+		}(pod)
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to drain %d pod(s) from node %s", len(failed), name)
+	}
+	return nil
+}
+
+// evictAndWait evicts a pod, retrying while a PDB blocks it, then waits until it is gone
+func (c *Client) evictAndWait(ctx context.Context, pod corev1.Pod, gracePeriod *int64, progress func(models.DrainEvent)) error {
+	ref := pod.Namespace + "/" + pod.Name
+	progress(models.DrainEvent{Pod: ref, Phase: "evicting"})
+
+	lastReason := ""
+	for {
+		err := c.EvictPod(ctx, pod.Namespace, pod.Name, gracePeriod)
+		if err == nil || apierrors.IsNotFound(err) {
+			break
+		}
+
+		var blockedErr *EvictionBlockedError
+		if !errors.As(err, &blockedErr) {
+			return err
+		}
+		// Only report the blocker again when the reason changes
+		if blockedErr.Reason != lastReason {
+			progress(models.DrainEvent{Pod: ref, Phase: "blocked", Message: blockedErr.Reason})
+			lastReason = blockedErr.Reason
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out while eviction was blocked: %s", lastReason)
+		case <-time.After(evictionRetryInterval):
+		}
+	}
+	progress(models.DrainEvent{Pod: ref, Phase: "evicted"})
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		current, err := c.Clientset.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		// A pod with the same name but a different UID is a replacement (StatefulSet)
+		if apierrors.IsNotFound(err) || (err == nil && current.UID != pod.UID) {
+			progress(models.DrainEvent{Pod: ref, Phase: "deleted"})
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for pod to terminate")
+		case <-ticker.C:
+		}
+	}
+}
+
+// classifyDrainPod returns a reason to skip the pod, a reason it blocks the
+// drain, or two empty strings if it should be evicted
+func classifyDrainPod(pod corev1.Pod, opts DrainOptions) (skip string, blocker string) {
+	if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+		return "mirror pod (managed by the kubelet)", ""
+	}
+
+	controller := metav1.GetControllerOf(&pod)
+	if controller != nil && controller.Kind == "DaemonSet" {
+		return "DaemonSet-managed pod", ""
+	}
+
+	// Finished pods can always be removed
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return "", ""
+	}
+
+	if controller == nil && !opts.Force {
+		return "", "pod is not managed by a controller and would be lost (use force)"
+	}
+
+	if !opts.DeleteEmptyDirData {
+		for _, v := range pod.Spec.Volumes {
+			if v.EmptyDir != nil {
+				return "", fmt.Sprintf("pod uses emptyDir volume %q whose data would be lost (use deleteEmptyDirData)", v.Name)
+			}
+		}
+	}
+
+	return "", ""
+}
@@ -3,6 +3,8 @@ package k8s
 import (
 	"context"
 	"fmt"
+	"log"
+	"sort"
 	"strings"
 	"time"
 
@@ -26,6 +28,128 @@ func (c *Client) GetNodes(ctx context.Context) ([]models.Node, error) {
 	return nodes, nil
 }
 
+// GetNodesWithAllocation returns all nodes with the requests and limits of
+// their non-terminated pods, computed from a single pod list. If pods can't
+// be listed the nodes are returned without allocation.
+func (c *Client) GetNodesWithAllocation(ctx context.Context) ([]models.Node, error) {
+	nodes, err := c.GetNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	podList, err := c.Clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+	})
+	if err != nil {
+		log.Printf("Failed to list pods for node allocation: %v", err)
+		return nodes, nil
+	}
+
+	byNode := make(map[string][]models.NodePod)
+	for _, p := range podList.Items {
+		if p.Spec.NodeName == "" {
+			continue
+		}
+		cpuReq, memReq, cpuLim, memLim := podResources(p)
+		byNode[p.Spec.NodeName] = append(byNode[p.Spec.NodeName], models.NodePod{
+			Name:          p.Name,
+			Namespace:     p.Namespace,
+			CPURequest:    cpuReq,
+			CPULimit:      cpuLim,
+			MemoryRequest: memReq,
+			MemoryLimit:   memLim,
+		})
+	}
+
+	for i := range nodes {
+		n := &nodes[i]
+		pods := byNode[n.Name]
+		for _, p := range pods {
+			n.CPURequests += p.CPURequest
+			n.CPULimits += p.CPULimit
+			n.MemoryRequests += p.MemoryRequest
+			n.MemoryLimits += p.MemoryLimit
+		}
+
+		n.PodCount = len(pods)
+		n.CPURequestPercent = ratio(n.CPURequests, n.CPUAllocatable) * 100
+		n.MemoryRequestPercent = ratio(n.MemoryRequests, n.MemoryAllocatable) * 100
+		n.CPUOvercommit = ratio(n.CPULimits, n.CPUAllocatable)
+		n.MemoryOvercommit = ratio(n.MemoryLimits, n.MemoryAllocatable)
+		n.CPUSchedulable = nonNegative(n.CPUAllocatable - n.CPURequests)
+		n.MemorySchedulable = nonNegative(n.MemoryAllocatable - n.MemoryRequests)
+		n.PodsSchedulable = nonNegative(n.PodAllocatable - int64(len(pods)))
+
+		// Largest reservations first
+		sort.Slice(pods, func(a, b int) bool {
+			if pods[a].CPURequest != pods[b].CPURequest {
+				return pods[a].CPURequest > pods[b].CPURequest
+			}
+			if pods[a].MemoryRequest != pods[b].MemoryRequest {
+				return pods[a].MemoryRequest > pods[b].MemoryRequest
+			}
+			return pods[a].Namespace+"/"+pods[a].Name < pods[b].Namespace+"/"+pods[b].Name
+		})
+		n.Pods = pods
+	}
+
+	return nodes, nil
+}
+
+// podResources returns the requests and limits the scheduler accounts for a
+// pod: the larger of the summed app and sidecar containers and the largest
+// init container, plus the pod overhead
+func podResources(p corev1.Pod) (cpuReq, memReq, cpuLim, memLim int64) {
+	containers := append([]corev1.Container(nil), p.Spec.Containers...)
+	var initContainers []corev1.Container
+	for _, c := range p.Spec.InitContainers {
+		if c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			// Sidecars keep running next to the app containers
+			containers = append(containers, c)
+		} else {
+			initContainers = append(initContainers, c)
+		}
+	}
+
+	for _, c := range containers {
+		cpuReq += c.Resources.Requests.Cpu().MilliValue()
+		memReq += c.Resources.Requests.Memory().Value()
+		cpuLim += c.Resources.Limits.Cpu().MilliValue()
+		memLim += c.Resources.Limits.Memory().Value()
+	}
+	for _, c := range initContainers {
+		cpuReq = max(cpuReq, c.Resources.Requests.Cpu().MilliValue())
+		memReq = max(memReq, c.Resources.Requests.Memory().Value())
+		cpuLim = max(cpuLim, c.Resources.Limits.Cpu().MilliValue())
+		memLim = max(memLim, c.Resources.Limits.Memory().Value())
+	}
+	if p.Spec.Overhead != nil {
+		cpuReq += p.Spec.Overhead.Cpu().MilliValue()
+		memReq += p.Spec.Overhead.Memory().Value()
+		if cpuLim > 0 {
+			cpuLim += p.Spec.Overhead.Cpu().MilliValue()
+		}
+		if memLim > 0 {
+			memLim += p.Spec.Overhead.Memory().Value()
+		}
+	}
+	return cpuReq, memReq, cpuLim, memLim
+}
+
+func ratio(part, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(part) / float64(total)
+}
+
+func nonNegative(v int64) int64 {
+	if v < 0 {
+		return 0
+	}
+	return v
+}
+
 func convertNode(n corev1.Node) models.Node {
 	// Get node status
 	status := "Unknown"
@@ -65,6 +189,7 @@ func convertNode(n corev1.Node) models.Node {
 	cpuCapacity := n.Status.Capacity.Cpu().MilliValue()
 	memCapacity := n.Status.Capacity.Memory().Value()
 	podCapacity := n.Status.Capacity.Pods().Value()
+	podAllocatable := n.Status.Allocatable.Pods().Value()
 
 	// Get allocatable
 	cpuAllocatable := n.Status.Allocatable.Cpu().MilliValue()
@@ -137,6 +262,7 @@ func convertNode(n corev1.Node) models.Node {
 		CPUAllocatable:    cpuAllocatable,
 		MemoryAllocatable: memAllocatable,
 		PodCapacity:       podCapacity,
+		PodAllocatable:    podAllocatable,
 		Age:               age,
 		CreatedAt:         n.CreationTimestamp.Time,
 		Conditions:        conditions,
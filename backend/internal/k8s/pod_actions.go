@@ -0,0 +1,108 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PodDeleteOptions represents options for deleting a pod
+type PodDeleteOptions struct {
+	GracePeriodSeconds *int64 // nil = pod default
+	Force              bool   // delete immediately without waiting for the kubelet
+}
+
+// EvictionBlockedError is returned when the Eviction API refuses an eviction
+// (HTTP 429), usually because a PodDisruptionBudget would be violated
+type EvictionBlockedError struct {
+	Pod    string
+	Reason string
+}
+
+func (e *EvictionBlockedError) Error() string {
+	return fmt.Sprintf("eviction of pod %s blocked: %s", e.Pod, e.Reason)
+}
+
+// DeletePod deletes a pod
+func (c *Client) DeletePod(ctx context.Context, namespace, name string, opts PodDeleteOptions) error {
+	deleteOpts := metav1.DeleteOptions{
+		GracePeriodSeconds: opts.GracePeriodSeconds,
+	}
+	if opts.Force {
+		// Same as `kubectl delete --force`: skip graceful termination entirely
+		var zero int64
+		deleteOpts.GracePeriodSeconds = &zero
+	}
+
+	if err := c.Clientset.CoreV1().Pods(namespace).Delete(ctx, name, deleteOpts); err != nil {
+		return fmt.Errorf("failed to delete pod: %w", err)
+	}
+	return nil
+}
+
+// EvictPod evicts a pod through the Eviction API, which respects PodDisruptionBudgets.
+// A refused eviction is reported as *EvictionBlockedError.
+func (c *Client) EvictPod(ctx context.Context, namespace, name string, gracePeriodSeconds *int64) error {
+	eviction := &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		DeleteOptions: &metav1.DeleteOptions{
+			GracePeriodSeconds: gracePeriodSeconds,
+		},
+	}
+
+	err := c.Clientset.PolicyV1().Evictions(namespace).Evict(ctx, eviction)
+	if err == nil {
+		return nil
+	}
+
+	if apierrors.IsTooManyRequests(err) {
+		return &EvictionBlockedError{Pod: name, Reason: evictionBlockReason(err)}
+	}
+	return fmt.Errorf("failed to evict pod: %w", err)
+}
+
+// ListPodNames returns the names of pods matching a label selector, sorted
+func (c *Client) ListPodNames(ctx context.Context, namespace, labelSelector string) ([]string, error) {
+	podList, err := c.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	names := make([]string, 0, len(podList.Items))
+	for _, p := range podList.Items {
+		names = append(names, p.Name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// evictionBlockReason collects the human readable reasons from a 429 status
+func evictionBlockReason(err error) string {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return err.Error()
+	}
+
+	s := status.Status()
+	reasons := []string{s.Message}
+	if s.Details != nil {
+		for _, cause := range s.Details.Causes {
+			if cause.Message != "" && cause.Message != s.Message {
+				reasons = append(reasons, cause.Message)
+			}
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
@@ -123,6 +123,9 @@ func convertPod(p corev1.Pod) models.Pod {
 				container.RestartCount = cs.RestartCount
 				container.State = getContainerState(cs.State)
 				container.StateDetails = getContainerStateDetails(cs.State)
+				if t := cs.LastTerminationState.Terminated; t != nil {
+					container.LastTerminationReason = t.Reason
+				}
 				if cs.State.Running != nil {
 					startedAt := cs.State.Running.StartedAt.Time
 					container.StartedAt = &startedAt
@@ -0,0 +1,222 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// Labels that differ between replicas of the same workload and must not be used
+// to find a replacement pod
+var perPodLabels = map[string]bool{
+	"pod-template-hash":                  true,
+	"controller-revision-hash":           true,
+	"statefulset.kubernetes.io/pod-name": true,
+	"apps.kubernetes.io/pod-index":       true,
+}
+
+// PortForwardSession is a running port-forward from localhost to a single pod
+type PortForwardSession struct {
+	LocalPort int
+	// Done receives the error that ended the forward (nil after Stop)
+	Done <-chan error
+	stop chan struct{}
+}
+
+// Stop closes the local listener and the connection to the pod
+func (s *PortForwardSession) Stop() {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+}
+
+// StartPortForward forwards localhost:localPort to remotePort of the pod.
+// A localPort of 0 binds a random free port, reported in the returned session.
+func (c *Client) StartPortForward(ctx context.Context, namespace, podName string, localPort, remotePort int) (*PortForwardSession, error) {
+	req := c.Clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(podName).
+		SubResource("portforward")
+
+	transport, upgrader, err := spdy.RoundTripperFor(c.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spdy transport: %w", err)
+	}
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", req.URL())
+
+	// Prefer websockets, falling back to SPDY for older API servers
+	tunnelingDialer, err := portforward.NewSPDYOverWebsocketDialer(req.URL(), c.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create websocket dialer: %w", err)
+	}
+	dialer = portforward.NewFallbackDialer(tunnelingDialer, dialer, func(err error) bool {
+		return httpstream.IsUpgradeFailure(err) || httpstream.IsHTTPSProxyError(err)
+	})
+
+	stop := make(chan struct{})
+	ready := make(chan struct{})
+	ports := []string{fmt.Sprintf("%d:%d", localPort, remotePort)}
+
+	forwarder, err := portforward.NewOnAddresses(dialer, []string{"localhost"}, ports, stop, ready, io.Discard, io.Discard)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create port forwarder: %w", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- forwarder.ForwardPorts()
+	}()
+
+	select {
+	case <-ready:
+	case err := <-done:
+		if err == nil {
+			err = fmt.Errorf("port forward stopped before becoming ready")
+		}
+		return nil, fmt.Errorf("failed to forward ports: %w", err)
+	case <-ctx.Done():
+		close(stop)
+		return nil, ctx.Err()
+	}
+
+	forwarded, err := forwarder.GetPorts()
+	if err != nil {
+		close(stop)
+		return nil, fmt.Errorf("failed to get forwarded ports: %w", err)
+	}
+	if len(forwarded) == 0 {
+		close(stop)
+		return nil, fmt.Errorf("failed to get forwarded ports: none reported")
+	}
+
+	return &PortForwardSession{
+		LocalPort: int(forwarded[0].Local),
+		Done:      done,
+		stop:      stop,
+	}, nil
+}
+
+// ResolveServicePod picks a ready pod backing the service port and returns the
+// pod name and the container port the service's targetPort maps to
+func (c *Client) ResolveServicePod(ctx context.Context, namespace, serviceName string, port int) (string, int, error) {
+	svc, err := c.Clientset.CoreV1().Services(namespace).Get(ctx, serviceName, metav1.GetOptions{})
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get service: %w", err)
+	}
+
+	var svcPort *corev1.ServicePort
+	for i := range svc.Spec.Ports {
+		if port == 0 || int(svc.Spec.Ports[i].Port) == port {
+			svcPort = &svc.Spec.Ports[i]
+			break
+		}
+	}
+	if svcPort == nil {
+		return "", 0, fmt.Errorf("service %s has no port %d", serviceName, port)
+	}
+
+	endpoints, err := c.GetServiceEndpoints(ctx, namespace, serviceName)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Endpoint ports carry the resolved targetPort (including named ports)
+	targetPort := svcPort.TargetPort.IntValue()
+	for _, p := range endpoints.Ports {
+		if p.Name == svcPort.Name {
+			targetPort = int(p.Port)
+			break
+		}
+	}
+	if targetPort == 0 {
+		targetPort = int(svcPort.Port)
+	}
+
+	for _, addr := range endpoints.Addresses {
+		if podName, ok := strings.CutPrefix(addr.TargetRef, "Pod/"); ok {
+			return podName, targetPort, nil
+		}
+	}
+
+	return "", 0, fmt.Errorf("service %s has no ready pods", serviceName)
+}
+
+// FindReplacementPod returns a running pod to use in place of podName: the pod
+// itself if it is still running, otherwise a ready pod of the same workload
+func (c *Client) FindReplacementPod(ctx context.Context, namespace, podName string, podLabels map[string]string) (string, error) {
+	pod, err := c.Clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err == nil && pod.DeletionTimestamp == nil && isPodReady(pod) {
+		return podName, nil
+	}
+
+	selector := make(labels.Set)
+	for k, v := range podLabels {
+		if !perPodLabels[k] {
+			selector[k] = v
+		}
+	}
+	if len(selector) == 0 {
+		return "", fmt.Errorf("pod %s is gone and has no labels to find a replacement", podName)
+	}
+
+	podList, err := c.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: selector.AsSelector().String(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	for i := range podList.Items {
+		if podList.Items[i].DeletionTimestamp == nil && isPodReady(&podList.Items[i]) {
+			return podList.Items[i].Name, nil
+		}
+	}
+
+	return "", fmt.Errorf("no ready replacement for pod %s", podName)
+}
+
+// IsPodRunning reports whether the pod still exists, is not being deleted and is running
+func (c *Client) IsPodRunning(ctx context.Context, namespace, podName string) (bool, error) {
+	pod, err := c.Clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get pod: %w", err)
+	}
+	return pod.DeletionTimestamp == nil && pod.Status.Phase == corev1.PodRunning, nil
+}
+
+// GetPodLabels returns the labels of a pod
+func (c *Client) GetPodLabels(ctx context.Context, namespace, podName string) (map[string]string, error) {
+	pod, err := c.Clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod: %w", err)
+	}
+	return pod.Labels, nil
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
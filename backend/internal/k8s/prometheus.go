@@ -0,0 +1,582 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/krzyzao/kub/internal/models"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	prometheusTimeout = 15 * time.Second
+	// Resolution of the subqueries finding the maximum within each step
+	peakResolution = 15 * time.Second
+)
+
+// PrometheusQueries are the PromQL templates used by the Prometheus provider.
+// {{.Selector}} expands to label matchers for the namespace and pod asked
+// for, e.g. namespace="default",pod="web-0", or namespace!="" for all. Node
+// queries must return a node label, container queries namespace, pod and
+// container labels, and I/O queries namespace and pod labels. CPU is in
+// cores, everything else in bytes or bytes per second.
+type PrometheusQueries struct {
+	NodeCPU         string `json:"nodeCPU,omitempty"`
+	NodeMemory      string `json:"nodeMemory,omitempty"`
+	ContainerCPU    string `json:"containerCPU,omitempty"`
+	ContainerMemory string `json:"containerMemory,omitempty"`
+	NetworkReceive  string `json:"networkReceive,omitempty"`
+	NetworkTransmit string `json:"networkTransmit,omitempty"`
+	DiskRead        string `json:"diskRead,omitempty"`
+	DiskWrite       string `json:"diskWrite,omitempty"`
+}
+
+// DefaultPrometheusQueries work with the cAdvisor metrics scraped from the
+// kubelet by kube-prometheus and most Prometheus Helm charts
+var DefaultPrometheusQueries = PrometheusQueries{
+	NodeCPU:         `sum by (node) (rate(container_cpu_usage_seconds_total{id="/"}[5m]))`,
+	NodeMemory:      `sum by (node) (container_memory_working_set_bytes{id="/"})`,
+	ContainerCPU:    `sum by (namespace, pod, container) (rate(container_cpu_usage_seconds_total{container!="",container!="POD",{{.Selector}}}[5m]))`,
+	ContainerMemory: `sum by (namespace, pod, container) (container_memory_working_set_bytes{container!="",container!="POD",{{.Selector}}})`,
+	NetworkReceive:  `sum by (namespace, pod) (rate(container_network_receive_bytes_total{pod!="",{{.Selector}}}[5m]))`,
+	NetworkTransmit: `sum by (namespace, pod) (rate(container_network_transmit_bytes_total{pod!="",{{.Selector}}}[5m]))`,
+	DiskRead:        `sum by (namespace, pod) (rate(container_fs_reads_bytes_total{container!="",{{.Selector}}}[5m]))`,
+	DiskWrite:       `sum by (namespace, pod) (rate(container_fs_writes_bytes_total{container!="",{{.Selector}}}[5m]))`,
+}
+
+// PrometheusConfig configures the Prometheus metrics providers
+type PrometheusConfig struct {
+	// URLs maps context names to the base URL of their Prometheus HTTP API.
+	// The "" key is the context current at startup. Contexts without a URL
+	// use metrics-server.
+	URLs    map[string]string
+	Token   string // optional bearer token
+	Queries PrometheusQueries
+}
+
+// PrometheusConfigFromEnv reads KUB_PROMETHEUS_URL, KUB_PROMETHEUS_TOKEN and
+// KUB_PROMETHEUS_QUERIES (a YAML file overriding some of the default
+// queries). KUB_PROMETHEUS_URL is either one URL for the context current at
+// startup or comma-separated context=url pairs.
+func PrometheusConfigFromEnv() (PrometheusConfig, error) {
+	cfg := PrometheusConfig{
+		Token:   os.Getenv("KUB_PROMETHEUS_TOKEN"),
+		Queries: DefaultPrometheusQueries,
+	}
+
+	urls, err := parsePrometheusURLs(os.Getenv("KUB_PROMETHEUS_URL"))
+	if err != nil {
+		return cfg, err
+	}
+	cfg.URLs = urls
+
+	path := os.Getenv("KUB_PROMETHEUS_QUERIES")
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read Prometheus queries: %w", err)
+	}
+	var overrides PrometheusQueries
+	if err := yaml.UnmarshalStrict(data, &overrides); err != nil {
+		return cfg, fmt.Errorf("failed to parse Prometheus queries: %w", err)
+	}
+	for _, q := range []struct{ override, target *string }{
+		{&overrides.NodeCPU, &cfg.Queries.NodeCPU},
+		{&overrides.NodeMemory, &cfg.Queries.NodeMemory},
+		{&overrides.ContainerCPU, &cfg.Queries.ContainerCPU},
+		{&overrides.ContainerMemory, &cfg.Queries.ContainerMemory},
+		{&overrides.NetworkReceive, &cfg.Queries.NetworkReceive},
+		{&overrides.NetworkTransmit, &cfg.Queries.NetworkTransmit},
+		{&overrides.DiskRead, &cfg.Queries.DiskRead},
+		{&overrides.DiskWrite, &cfg.Queries.DiskWrite},
+	} {
+		if *q.override != "" {
+			*q.target = *q.override
+		}
+	}
+	return cfg, nil
+}
+
+// parsePrometheusURLs parses a single URL or context=url pairs. Context
+// names may contain slashes and colons, so an entry is a bare URL only when
+// it starts with a scheme.
+func parsePrometheusURLs(value string) (map[string]string, error) {
+	urls := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		contextName, u := "", entry
+		if !strings.HasPrefix(entry, "http://") && !strings.HasPrefix(entry, "https://") {
+			var ok bool
+			contextName, u, ok = strings.Cut(entry, "=")
+			contextName, u = strings.TrimSpace(contextName), strings.TrimSpace(u)
+			if !ok || contextName == "" {
+				return nil, fmt.Errorf("invalid KUB_PROMETHEUS_URL entry %q: expected a URL or context=url", entry)
+			}
+		}
+		if _, exists := urls[contextName]; exists {
+			if contextName == "" {
+				return nil, fmt.Errorf("KUB_PROMETHEUS_URL has more than one URL without a context")
+			}
+			return nil, fmt.Errorf("KUB_PROMETHEUS_URL has more than one URL for context %s", contextName)
+		}
+		urls[contextName] = strings.TrimSuffix(u, "/")
+	}
+	return urls, nil
+}
+
+// prometheusProvider queries the Prometheus HTTP API. Besides current usage
+// it serves history and network and disk I/O.
+type prometheusProvider struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+
+	nodeCPU, nodeMemory           *template.Template
+	containerCPU, containerMemory *template.Template
+	netReceive, netTransmit       *template.Template
+	diskRead, diskWrite           *template.Template
+}
+
+// NewPrometheusProvider creates a provider for the Prometheus server at
+// baseURL with the token and queries of cfg
+func NewPrometheusProvider(baseURL string, cfg PrometheusConfig) (MetricsProvider, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid Prometheus URL %q", baseURL)
+	}
+
+	p := &prometheusProvider{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      cfg.Token,
+		httpClient: &http.Client{Timeout: prometheusTimeout},
+	}
+	for _, t := range []struct {
+		name, text string
+		target     **template.Template
+	}{
+		{"nodeCPU", cfg.Queries.NodeCPU, &p.nodeCPU},
+		{"nodeMemory", cfg.Queries.NodeMemory, &p.nodeMemory},
+		{"containerCPU", cfg.Queries.ContainerCPU, &p.containerCPU},
+		{"containerMemory", cfg.Queries.ContainerMemory, &p.containerMemory},
+		{"networkReceive", cfg.Queries.NetworkReceive, &p.netReceive},
+		{"networkTransmit", cfg.Queries.NetworkTransmit, &p.netTransmit},
+		{"diskRead", cfg.Queries.DiskRead, &p.diskRead},
+		{"diskWrite", cfg.Queries.DiskWrite, &p.diskWrite},
+	} {
+		if t.text == "" {
+			return nil, fmt.Errorf("Prometheus query %s is empty", t.name)
+		}
+		tmpl, err := template.New(t.name).Option("missingkey=error").Parse(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Prometheus query %s: %w", t.name, err)
+		}
+		*t.target = tmpl
+	}
+	return p, nil
+}
+
+func (p *prometheusProvider) Name() string {
+	return "prometheus"
+}
+
+func (p *prometheusProvider) NodeUsage(ctx context.Context) ([]NodeUsage, error) {
+	cpu, err := p.instant(ctx, p.nodeCPU, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node metrics: %w", err)
+	}
+	memory, err := p.instant(ctx, p.nodeMemory, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node metrics: %w", err)
+	}
+
+	nodes := make(map[string]*NodeUsage)
+	get := func(name string) *NodeUsage {
+		n := nodes[name]
+		if n == nil {
+			n = &NodeUsage{Name: name}
+			nodes[name] = n
+		}
+		return n
+	}
+	for _, s := range cpu {
+		if name := s.Metric["node"]; name != "" {
+			get(name).CPU = millicores(s.value())
+		}
+	}
+	for _, s := range memory {
+		if name := s.Metric["node"]; name != "" {
+			get(name).Memory = int64(s.value())
+		}
+	}
+
+	usage := make([]NodeUsage, 0, len(nodes))
+	for _, n := range nodes {
+		usage = append(usage, *n)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Name < usage[j].Name })
+	return usage, nil
+}
+
+func (p *prometheusProvider) PodUsage(ctx context.Context, namespace string) ([]PodUsage, error) {
+	if namespace == "all" {
+		namespace = ""
+	}
+
+	cpu, err := p.instant(ctx, p.containerCPU, namespace, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod metrics: %w", err)
+	}
+	memory, err := p.instant(ctx, p.containerMemory, namespace, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod metrics: %w", err)
+	}
+
+	pods := make(map[string]*PodUsage)
+	var order []string
+	get := func(m map[string]string) *ContainerUsage {
+		ns, name, container := m["namespace"], m["pod"], m["container"]
+		if ns == "" || name == "" || container == "" {
+			return nil
+		}
+		key := ns + "/" + name
+		pod := pods[key]
+		if pod == nil {
+			pod = &PodUsage{Name: name, Namespace: ns}
+			pods[key] = pod
+			order = append(order, key)
+		}
+		for i := range pod.Containers {
+			if pod.Containers[i].Name == container {
+				return &pod.Containers[i]
+			}
+		}
+		pod.Containers = append(pod.Containers, ContainerUsage{Name: container})
+		return &pod.Containers[len(pod.Containers)-1]
+	}
+	for _, s := range cpu {
+		if c := get(s.Metric); c != nil {
+			c.CPU = millicores(s.value())
+		}
+	}
+	for _, s := range memory {
+		if c := get(s.Metric); c != nil {
+			c.Memory = int64(s.value())
+		}
+	}
+
+	sort.Strings(order)
+	usage := make([]PodUsage, 0, len(order))
+	for _, key := range order {
+		usage = append(usage, *pods[key])
+	}
+	return usage, nil
+}
+
+func (p *prometheusProvider) PodIO(ctx context.Context, namespace, pod string) ([]models.PodIO, error) {
+	if namespace == "all" {
+		namespace = ""
+	}
+
+	pods := make(map[string]*models.PodIO)
+	for _, q := range []struct {
+		tmpl  *template.Template
+		field func(*models.PodIO) *float64
+	}{
+		{p.netReceive, func(io *models.PodIO) *float64 { return &io.NetworkReceive }},
+		{p.netTransmit, func(io *models.PodIO) *float64 { return &io.NetworkTransmit }},
+		{p.diskRead, func(io *models.PodIO) *float64 { return &io.DiskRead }},
+		{p.diskWrite, func(io *models.PodIO) *float64 { return &io.DiskWrite }},
+	} {
+		result, err := p.instant(ctx, q.tmpl, namespace, pod)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pod I/O metrics: %w", err)
+		}
+		for _, s := range result {
+			ns, name := s.Metric["namespace"], s.Metric["pod"]
+			if ns == "" || name == "" {
+				continue
+			}
+			key := ns + "/" + name
+			entry := pods[key]
+			if entry == nil {
+				entry = &models.PodIO{Name: name, Namespace: ns}
+				pods[key] = entry
+			}
+			*q.field(entry) = s.value()
+		}
+	}
+
+	usage := make([]models.PodIO, 0, len(pods))
+	for _, entry := range pods {
+		usage = append(usage, *entry)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Namespace != usage[j].Namespace {
+			return usage[i].Namespace < usage[j].Namespace
+		}
+		return usage[i].Name < usage[j].Name
+	})
+	return usage, nil
+}
+
+// UsageRange returns CPU and memory series with one point per step. Pod and
+// namespace series are the container queries summed by pod and namespace.
+// With q.Max, points also carry the maximum within their step, sampled
+// every peakResolution.
+func (p *prometheusProvider) UsageRange(ctx context.Context, q UsageRangeQuery) ([]models.MetricsSeries, error) {
+	var result []models.MetricsSeries
+
+	if q.Kind == "" || q.Kind == "node" {
+		series, err := p.usageRange(ctx, "node", p.nodeCPU, p.nodeMemory, "", "", "", q, func(m map[string]string) (string, string) {
+			return m["node"], ""
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get node history: %w", err)
+		}
+		for _, s := range series {
+			if q.Name == "" || s.Name == q.Name {
+				result = append(result, s)
+			}
+		}
+	}
+
+	if q.Kind == "" || q.Kind == "pod" {
+		series, err := p.usageRange(ctx, "pod", p.containerCPU, p.containerMemory, "namespace, pod", q.Namespace, q.Name, q, func(m map[string]string) (string, string) {
+			return m["pod"], m["namespace"]
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pod history: %w", err)
+		}
+		result = append(result, series...)
+	}
+
+	// A namespace series is named after its namespace, so name and namespace
+	// both select it
+	namespace := q.Namespace
+	if q.Name != "" {
+		namespace = q.Name
+	}
+	if (q.Kind == "" || q.Kind == "namespace") && (q.Namespace == "" || namespace == q.Namespace) {
+		series, err := p.usageRange(ctx, "namespace", p.containerCPU, p.containerMemory, "namespace", namespace, "", q, func(m map[string]string) (string, string) {
+			return m["namespace"], ""
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get namespace history: %w", err)
+		}
+		result = append(result, series...)
+	}
+
+	return result, nil
+}
+
+// usageRange runs the CPU and memory range queries of one kind of series
+// and, with q.Max, their maximum within each step. Steps no longer than
+// peakResolution have nothing to take the maximum of.
+func (p *prometheusProvider) usageRange(ctx context.Context, kind string, cpuTmpl, memoryTmpl *template.Template, sumBy, namespace, pod string, q UsageRangeQuery, id func(map[string]string) (name, namespace string)) ([]models.MetricsSeries, error) {
+	peaks := q.Max && q.Step > peakResolution
+	var results [4][]promSample // cpu, memory, cpu max, memory max
+	for i, tmpl := range []*template.Template{cpuTmpl, memoryTmpl} {
+		var err error
+		if results[i], err = p.rangeQuery(ctx, tmpl, sumBy, namespace, pod, q, false); err != nil {
+			return nil, err
+		}
+		if peaks {
+			if results[i+2], err = p.rangeQuery(ctx, tmpl, sumBy, namespace, pod, q, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return mergeSeries(kind, id, results[0], results[1], results[2], results[3]), nil
+}
+
+// promSample is one series of a Prometheus vector or matrix result
+type promSample struct {
+	Metric map[string]string `json:"metric"`
+	Value  [2]interface{}    `json:"value"`  // vector: [unix seconds, "value"]
+	Values [][2]interface{}  `json:"values"` // matrix
+}
+
+func (s promSample) value() float64 {
+	return sampleValue(s.Value)
+}
+
+func sampleValue(v [2]interface{}) float64 {
+	str, _ := v[1].(string)
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0
+	}
+	return f
+}
+
+func sampleTime(v [2]interface{}) int64 {
+	ts, _ := v[0].(float64)
+	return int64(math.Round(ts * 1000))
+}
+
+type promResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string       `json:"resultType"`
+		Result     []promSample `json:"result"`
+	} `json:"data"`
+}
+
+// instant runs a query at the current time
+func (p *prometheusProvider) instant(ctx context.Context, tmpl *template.Template, namespace, pod string) ([]promSample, error) {
+	query, err := renderQuery(tmpl, namespace, pod)
+	if err != nil {
+		return nil, err
+	}
+	return p.do(ctx, "/api/v1/query", url.Values{"query": {query}})
+}
+
+// rangeQuery runs a query over the time range of q. A non-empty sumBy
+// wraps the query in a sum by those labels; peak takes the maximum of the
+// result within each step.
+func (p *prometheusProvider) rangeQuery(ctx context.Context, tmpl *template.Template, sumBy, namespace, pod string, q UsageRangeQuery, peak bool) ([]promSample, error) {
+	query, err := renderQuery(tmpl, namespace, pod)
+	if err != nil {
+		return nil, err
+	}
+	if sumBy != "" {
+		query = "sum by (" + sumBy + ") (" + query + ")"
+	}
+	if peak {
+		query = fmt.Sprintf("max_over_time((%s)[%dms:%dms])", query, q.Step.Milliseconds(), peakResolution.Milliseconds())
+	}
+	return p.do(ctx, "/api/v1/query_range", url.Values{
+		"query": {query},
+		"start": {strconv.FormatFloat(float64(q.From.UnixMilli())/1000, 'f', 3, 64)},
+		"end":   {strconv.FormatFloat(float64(q.To.UnixMilli())/1000, 'f', 3, 64)},
+		"step":  {strconv.FormatFloat(q.Step.Seconds(), 'f', -1, 64)},
+	})
+}
+
+func (p *prometheusProvider) do(ctx context.Context, path string, params url.Values) ([]promSample, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Prometheus request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Prometheus: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body promResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<20)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode Prometheus response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if body.Status != "success" {
+		return nil, fmt.Errorf("Prometheus query failed: %s: %s", body.ErrorType, body.Error)
+	}
+	return body.Data.Result, nil
+}
+
+// renderQuery expands a query template with label matchers for namespace
+// and pod
+func renderQuery(tmpl *template.Template, namespace, pod string) (string, error) {
+	var matchers []string
+	if namespace != "" {
+		matchers = append(matchers, "namespace="+strconv.Quote(namespace))
+	} else {
+		matchers = append(matchers, `namespace!=""`)
+	}
+	if pod != "" {
+		matchers = append(matchers, "pod="+strconv.Quote(pod))
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, struct{ Selector string }{strings.Join(matchers, ",")}); err != nil {
+		return "", fmt.Errorf("failed to render Prometheus query %s: %w", tmpl.Name(), err)
+	}
+	return sb.String(), nil
+}
+
+// mergeSeries joins CPU and memory matrices, and optionally their per-step
+// maximums, into series keyed by the name and namespace that id extracts
+// from the labels
+func mergeSeries(kind string, id func(map[string]string) (name, namespace string), cpu, memory, cpuMax, memoryMax []promSample) []models.MetricsSeries {
+	type entry struct {
+		series models.MetricsSeries
+		points map[int64]*models.MetricsPoint
+	}
+	entries := make(map[string]*entry)
+	add := func(samples []promSample, set func(*models.MetricsPoint, float64)) {
+		for _, s := range samples {
+			name, namespace := id(s.Metric)
+			if name == "" {
+				continue
+			}
+			key := namespace + "/" + name
+			e := entries[key]
+			if e == nil {
+				e = &entry{
+					series: models.MetricsSeries{Kind: kind, Name: name, Namespace: namespace},
+					points: make(map[int64]*models.MetricsPoint),
+				}
+				entries[key] = e
+			}
+			for _, v := range s.Values {
+				ts := sampleTime(v)
+				p := e.points[ts]
+				if p == nil {
+					p = &models.MetricsPoint{Timestamp: ts}
+					e.points[ts] = p
+				}
+				set(p, sampleValue(v))
+			}
+		}
+	}
+	add(cpu, func(p *models.MetricsPoint, v float64) { p.CPUUsage = millicores(v) })
+	add(memory, func(p *models.MetricsPoint, v float64) { p.MemoryUsage = int64(v) })
+	add(cpuMax, func(p *models.MetricsPoint, v float64) { p.CPUMax = millicores(v) })
+	add(memoryMax, func(p *models.MetricsPoint, v float64) { p.MemoryMax = int64(v) })
+
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]models.MetricsSeries, 0, len(keys))
+	for _, key := range keys {
+		e := entries[key]
+		e.series.Points = make([]models.MetricsPoint, 0, len(e.points))
+		for _, p := range e.points {
+			e.series.Points = append(e.series.Points, *p)
+		}
+		sort.Slice(e.series.Points, func(i, j int) bool { return e.series.Points[i].Timestamp < e.series.Points[j].Timestamp })
+		result = append(result, e.series)
+	}
+	return result
+}
+
+// millicores converts cores to millicores
+func millicores(cores float64) int64 {
+	return int64(math.Round(cores * 1000))
+}
@@ -0,0 +1,189 @@
+package k8s
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakePrometheus serves the response whose key is the longest substring of
+// the query sent to it
+func fakePrometheus(t *testing.T, responses map[string]string) *prometheusProvider {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %v", err)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q, want bearer token", got)
+		}
+		query := r.PostForm.Get("query")
+		var match string
+		for key, body := range responses {
+			if strings.Contains(query, key) && strings.HasSuffix(r.URL.Path, pathFor(body)) && len(key) > len(match) {
+				match = key
+			}
+		}
+		if match == "" {
+			t.Errorf("unexpected query %s %q", r.URL.Path, query)
+			http.Error(w, "unexpected query", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(responses[match]))
+	}))
+	t.Cleanup(srv.Close)
+
+	p, err := NewPrometheusProvider(srv.URL, PrometheusConfig{Token: "secret", Queries: DefaultPrometheusQueries})
+	if err != nil {
+		t.Fatalf("NewPrometheusProvider: %v", err)
+	}
+	return p.(*prometheusProvider)
+}
+
+// pathFor routes matrix responses to query_range and everything else to query
+func pathFor(body string) string {
+	if strings.Contains(body, `"matrix"`) {
+		return "/query_range"
+	}
+	return "/query"
+}
+
+func TestPrometheusInstant(t *testing.T) {
+	p := fakePrometheus(t, map[string]string{
+		"container_cpu_usage_seconds_total": `{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"namespace":"default","pod":"web-0","container":"app"},"value":[1700000000,"0.25"]},
+			{"metric":{"namespace":"default","pod":"web-0","container":"sidecar"},"value":[1700000000,"NaN"]}]}}`,
+		"container_memory_working_set_bytes": `{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"namespace":"default","pod":"web-0","container":"app"},"value":[1700000000,"134217728"]}]}}`,
+	})
+
+	usage, err := p.PodUsage(context.Background(), "default")
+	if err != nil {
+		t.Fatalf("PodUsage: %v", err)
+	}
+	if len(usage) != 1 || usage[0].Name != "web-0" || usage[0].Namespace != "default" {
+		t.Fatalf("PodUsage = %+v, want web-0 in default", usage)
+	}
+	containers := make(map[string]ContainerUsage)
+	for _, c := range usage[0].Containers {
+		containers[c.Name] = c
+	}
+	if c := containers["app"]; c.CPU != 250 || c.Memory != 128<<20 {
+		t.Errorf("app = %+v, want 250m and 128Mi", c)
+	}
+	// NaN samples count as no usage
+	if c, ok := containers["sidecar"]; !ok || c.CPU != 0 {
+		t.Errorf("sidecar = %+v (present %v), want 0 CPU", c, ok)
+	}
+}
+
+func TestPrometheusRange(t *testing.T) {
+	p := fakePrometheus(t, map[string]string{
+		"container_cpu_usage_seconds_total": `{"status":"success","data":{"resultType":"matrix","result":[
+			{"metric":{"namespace":"default","pod":"web-0"},"values":[[1700000000,"0.1"],[1700000060,"NaN"],[1700000120,"0.3"]]}]}}`,
+		"container_memory_working_set_bytes": `{"status":"success","data":{"resultType":"matrix","result":[
+			{"metric":{"namespace":"default","pod":"web-0"},"values":[[1700000000,"1000"],[1700000060,"2000"]]}]}}`,
+	})
+
+	to := time.Unix(1700000120, 0)
+	series, err := p.UsageRange(context.Background(), UsageRangeQuery{
+		Kind:      "pod",
+		Namespace: "default",
+		From:      to.Add(-2 * time.Minute),
+		To:        to,
+		Step:      time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("UsageRange: %v", err)
+	}
+	if len(series) != 1 || series[0].Kind != "pod" || series[0].Name != "web-0" {
+		t.Fatalf("UsageRange = %+v, want one web-0 pod series", series)
+	}
+
+	points := series[0].Points
+	want := []struct {
+		ts          int64
+		cpu, memory int64
+	}{
+		{1700000000000, 100, 1000},
+		{1700000060000, 0, 2000},
+		{1700000120000, 300, 0},
+	}
+	if len(points) != len(want) {
+		t.Fatalf("got %d points, want %d: %+v", len(points), len(want), points)
+	}
+	for i, w := range want {
+		if points[i].Timestamp != w.ts || points[i].CPUUsage != w.cpu || points[i].MemoryUsage != w.memory {
+			t.Errorf("point %d = %+v, want %+v", i, points[i], w)
+		}
+	}
+}
+
+func TestPrometheusRangeMax(t *testing.T) {
+	p := fakePrometheus(t, map[string]string{
+		"container_cpu_usage_seconds_total": `{"status":"success","data":{"resultType":"matrix","result":[
+			{"metric":{"namespace":"default","pod":"web-0"},"values":[[1700000000,"0.1"]]}]}}`,
+		"container_memory_working_set_bytes": `{"status":"success","data":{"resultType":"matrix","result":[
+			{"metric":{"namespace":"default","pod":"web-0"},"values":[[1700000000,"1000"]]}]}}`,
+		"max_over_time((sum by (namespace, pod) (sum by (namespace, pod, container) (rate(container_cpu_usage_seconds_total": `{"status":"success","data":{"resultType":"matrix","result":[
+			{"metric":{"namespace":"default","pod":"web-0"},"values":[[1700000000,"0.4"]]}]}}`,
+		"max_over_time((sum by (namespace, pod) (sum by (namespace, pod, container) (container_memory_working_set_bytes": `{"status":"success","data":{"resultType":"matrix","result":[
+			{"metric":{"namespace":"default","pod":"web-0"},"values":[[1700000000,"5000"]]}]}}`,
+	})
+
+	to := time.Unix(1700000000, 0)
+	series, err := p.UsageRange(context.Background(), UsageRangeQuery{
+		Kind:      "pod",
+		Namespace: "default",
+		From:      to.Add(-time.Hour),
+		To:        to,
+		Step:      5 * time.Minute,
+		Max:       true,
+	})
+	if err != nil {
+		t.Fatalf("UsageRange: %v", err)
+	}
+	if len(series) != 1 || len(series[0].Points) != 1 {
+		t.Fatalf("UsageRange = %+v, want one point", series)
+	}
+	if pt := series[0].Points[0]; pt.CPUUsage != 100 || pt.CPUMax != 400 || pt.MemoryUsage != 1000 || pt.MemoryMax != 5000 {
+		t.Errorf("point = %+v, want 100m/400m and 1000/5000 bytes", pt)
+	}
+}
+
+func TestPrometheusError(t *testing.T) {
+	p := fakePrometheus(t, map[string]string{
+		"container_cpu_usage_seconds_total": `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+	})
+
+	_, err := p.PodUsage(context.Background(), "")
+	if err == nil {
+		t.Fatal("PodUsage succeeded, want error")
+	}
+	if !strings.Contains(err.Error(), "bad_data: parse error") {
+		t.Errorf("error = %v, want the Prometheus error", err)
+	}
+}
+
+func TestParsePrometheusURLs(t *testing.T) {
+	urls, err := parsePrometheusURLs("http://prometheus:9090/")
+	if err != nil || len(urls) != 1 || urls[""] != "http://prometheus:9090" {
+		t.Errorf("single URL = %v, %v", urls, err)
+	}
+
+	urls, err = parsePrometheusURLs("prod=https://prom.prod:9090, arn:aws:eks:eu-west-1:1:cluster/dev=http://prom.dev")
+	if err != nil || len(urls) != 2 || urls["prod"] != "https://prom.prod:9090" || urls["arn:aws:eks:eu-west-1:1:cluster/dev"] != "http://prom.dev" {
+		t.Errorf("pairs = %v, %v", urls, err)
+	}
+
+	if _, err := parsePrometheusURLs("prod=http://a,prod=http://b"); err == nil {
+		t.Error("duplicate context succeeded, want error")
+	}
+	if _, err := parsePrometheusURLs("prometheus:9090"); err == nil {
+		t.Error("entry without scheme or context succeeded, want error")
+	}
+}
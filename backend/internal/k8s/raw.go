@@ -0,0 +1,153 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+)
+
+// CoreGroup is the URL placeholder for the legacy core API group ("")
+const CoreGroup = "core"
+
+// ErrNamespaceRequired is returned when a namespaced kind is addressed
+// without a namespace
+var ErrNamespaceRequired = errors.New("kind is namespaced, a namespace is required")
+
+// ExportOptions represents options for a namespace export
+type ExportOptions struct {
+	Clean          bool // strip server-populated fields and controller-owned objects
+	IncludeSecrets bool
+}
+
+// Resources never worth exporting: high churn, derived or not re-appliable
+var exportSkippedResources = map[string]bool{
+	"events":                          true,
+	"events.events.k8s.io":            true,
+	"endpoints":                       true,
+	"endpointslices.discovery.k8s.io": true,
+	"pods.metrics.k8s.io":             true,
+	"localsubjectaccessreviews.authorization.k8s.io": true,
+	"controllerrevisions.apps":                       true,
+	"leases.coordination.k8s.io":                     true,
+}
+
+// GetRawObject returns the full object as the API server serves it. The group
+// is "core" for the legacy API group. With clean set, managedFields, status and
+// other server-populated fields are removed so the result can be re-applied.
+func (c *Client) GetRawObject(ctx context.Context, group, version, kind, namespace, name string, clean bool) (*unstructured.Unstructured, error) {
+	resource, err := c.objectResource(group, version, kind, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := resource.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", kind, err)
+	}
+
+	if clean {
+		obj = cleanObject(obj, true)
+	}
+	return obj, nil
+}
+
+// objectResource maps a kind addressed by URL to its resource client. Unlike
+// manifests, an empty namespace is not defaulted: namespaced kinds need one.
+func (c *Client) objectResource(group, version, kind, namespace string) (dynamic.ResourceInterface, error) {
+	resource, effective, err := c.resourceFor(groupVersionKind(group, version, kind), namespace, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if namespace == "" && effective != "" {
+		return nil, fmt.Errorf("%s: %w", kind, ErrNamespaceRequired)
+	}
+	return resource, nil
+}
+
+// ExportNamespace lists every listable namespaced resource in a namespace,
+// sorted by kind and name. Resource types that cannot be listed (RBAC,
+// broken aggregated APIs) are skipped.
+func (c *Client) ExportNamespace(ctx context.Context, namespace string, opts ExportOptions) ([]*unstructured.Unstructured, error) {
+	resourceLists, err := c.Clientset.Discovery().ServerPreferredNamespacedResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("failed to discover resources: %w", err)
+	}
+
+	var objects []*unstructured.Unstructured
+	for _, list := range resourceLists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+
+		for _, res := range list.APIResources {
+			if strings.Contains(res.Name, "/") || !hasVerb(res.Verbs, "list") {
+				continue
+			}
+			gvr := gv.WithResource(res.Name)
+			if exportSkippedResources[gvr.GroupResource().String()] {
+				continue
+			}
+			if gvr.GroupResource().String() == "secrets" && !opts.IncludeSecrets {
+				continue
+			}
+
+			items, err := c.DynamicClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				if apierrors.IsForbidden(err) || apierrors.IsNotFound(err) || apierrors.IsMethodNotSupported(err) {
+					continue
+				}
+				return nil, fmt.Errorf("failed to list %s: %w", gvr.GroupResource().String(), err)
+			}
+
+			for i := range items.Items {
+				obj := &items.Items[i]
+				// Objects created by a controller are recreated by their owner
+				if opts.Clean && metav1.GetControllerOf(obj) != nil {
+					continue
+				}
+				// List responses omit apiVersion/kind on items
+				obj.SetGroupVersionKind(gv.WithKind(res.Kind))
+				if opts.Clean {
+					obj = cleanObject(obj, true)
+				}
+				objects = append(objects, obj)
+			}
+		}
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		if objects[i].GetKind() != objects[j].GetKind() {
+			return objects[i].GetKind() < objects[j].GetKind()
+		}
+		return objects[i].GetName() < objects[j].GetName()
+	})
+
+	return objects, nil
+}
+
+// groupVersionKind builds a GVK from URL parameters, mapping "core" to the legacy group
+func groupVersionKind(group, version, kind string) schema.GroupVersionKind {
+	if group == CoreGroup {
+		group = ""
+	}
+	return schema.GroupVersionKind{Group: group, Version: version, Kind: kind}
+}
+
+func hasVerb(verbs metav1.Verbs, verb string) bool {
+	for _, v := range verbs {
+		if v == verb {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,324 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/krzyzao/kub/internal/models"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Rollback errors caused by the request rather than the cluster
+var (
+	ErrRevisionNotFound  = errors.New("revision not found")
+	ErrDeploymentPaused  = errors.New("deployment is paused")
+	ErrDeploymentChanged = errors.New("deployment was changed during the rollback")
+)
+
+const (
+	revisionAnnotation    = "deployment.kubernetes.io/revision"
+	changeCauseAnnotation = "kubernetes.io/change-cause"
+	restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+	// Default used by the deployment controller when progressDeadlineSeconds is unset
+	defaultProgressDeadline = 600 * time.Second
+)
+
+// GetDeploymentRevisions returns the rollout history of a deployment, newest first
+func (c *Client) GetDeploymentRevisions(ctx context.Context, namespace, name string) ([]models.DeploymentRevision, error) {
+	deployment, err := c.Clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+
+	replicaSets, err := c.getOwnedReplicaSets(ctx, deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	currentRevision := deployment.Annotations[revisionAnnotation]
+
+	revisions := make([]models.DeploymentRevision, 0, len(replicaSets))
+	for _, rs := range replicaSets {
+		revision, err := strconv.ParseInt(rs.Annotations[revisionAnnotation], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		images := make([]string, 0, len(rs.Spec.Template.Spec.Containers))
+		for _, c := range rs.Spec.Template.Spec.Containers {
+			images = append(images, c.Image)
+		}
+
+		var replicas int32
+		if rs.Spec.Replicas != nil {
+			replicas = *rs.Spec.Replicas
+		}
+
+		revisions = append(revisions, models.DeploymentRevision{
+			Revision:    revision,
+			ReplicaSet:  rs.Name,
+			Images:      images,
+			ChangeCause: rs.Annotations[changeCauseAnnotation],
+			Replicas:    replicas,
+			CreatedAt:   rs.CreationTimestamp.Time,
+			Current:     rs.Annotations[revisionAnnotation] == currentRevision,
+		})
+	}
+
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Revision > revisions[j].Revision
+	})
+
+	return revisions, nil
+}
+
+// RollbackDeployment rolls a deployment back to the pod template of the given revision.
+// A revision of 0 means the revision before the current one. With dryRun set the
+// deployment is left untouched and only the template diff is returned.
+func (c *Client) RollbackDeployment(ctx context.Context, namespace, name string, revision int64, dryRun bool) (*models.RollbackResult, error) {
+	deployment, err := c.Clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+
+	if deployment.Spec.Paused {
+		return nil, fmt.Errorf("%w, resume %s before rolling back", ErrDeploymentPaused, name)
+	}
+
+	replicaSets, err := c.getOwnedReplicaSets(ctx, deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	currentRevision, _ := strconv.ParseInt(deployment.Annotations[revisionAnnotation], 10, 64)
+
+	target, err := findRevision(replicaSets, revision, currentRevision)
+	if err != nil {
+		return nil, err
+	}
+	targetRevision, _ := strconv.ParseInt(target.Annotations[revisionAnnotation], 10, 64)
+
+	// The pod-template-hash label is added by the controller and must not be copied back
+	template := target.Spec.Template.DeepCopy()
+	delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+
+	diff, err := yamlDiff(
+		fmt.Sprintf("revision %d (current)", currentRevision),
+		fmt.Sprintf("revision %d", targetRevision),
+		deployment.Spec.Template,
+		template,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.RollbackResult{
+		Namespace:    namespace,
+		Name:         name,
+		FromRevision: currentRevision,
+		ToRevision:   targetRevision,
+		DryRun:       dryRun,
+		Changed:      !apiequality.Semantic.DeepEqual(deployment.Spec.Template, *template),
+		Diff:         diff,
+	}
+
+	if dryRun || !result.Changed {
+		return result, nil
+	}
+
+	patch, err := buildRollbackPatch(deployment, template, target)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.Clientset.AppsV1().Deployments(namespace).Patch(ctx, name, types.JSONPatchType, patch, metav1.PatchOptions{})
+	if apierrors.IsInvalid(err) || apierrors.IsConflict(err) {
+		// A failed resourceVersion test op is reported as invalid; tell it
+		// apart from an invalid template by reading the deployment again
+		current, getErr := c.Clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if getErr == nil && current.ResourceVersion != deployment.ResourceVersion {
+			return nil, fmt.Errorf("%w, retry: %v", ErrDeploymentChanged, err)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to roll back deployment: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetRolloutStatus reports the progress of the latest rollout of a deployment
+func (c *Client) GetRolloutStatus(ctx context.Context, namespace, name string) (*models.RolloutStatus, error) {
+	d, err := c.Clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+
+	var replicas int32 = 1
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+
+	deadline := defaultProgressDeadline
+	if d.Spec.ProgressDeadlineSeconds != nil {
+		deadline = time.Duration(*d.Spec.ProgressDeadlineSeconds) * time.Second
+	}
+
+	status := &models.RolloutStatus{
+		Namespace:               namespace,
+		Name:                    name,
+		Revision:                d.Annotations[revisionAnnotation],
+		Replicas:                replicas,
+		UpdatedReplicas:         d.Status.UpdatedReplicas,
+		ReadyReplicas:           d.Status.ReadyReplicas,
+		AvailableReplicas:       d.Status.AvailableReplicas,
+		ProgressDeadlineSeconds: int64(deadline.Seconds()),
+		Timestamp:               time.Now().UnixMilli(),
+	}
+
+	// Same checks as `kubectl rollout status`
+	switch {
+	case d.Generation > d.Status.ObservedGeneration:
+		status.Phase = "Pending"
+		status.Message = "waiting for deployment spec update to be observed"
+	case hasProgressDeadlineExceeded(d):
+		status.Phase = "Failed"
+		status.Message = fmt.Sprintf("deployment %q exceeded its progress deadline", name)
+	case d.Status.UpdatedReplicas < replicas:
+		status.Phase = "Progressing"
+		status.Message = fmt.Sprintf("%d out of %d new replicas have been updated", d.Status.UpdatedReplicas, replicas)
+	case d.Status.Replicas > d.Status.UpdatedReplicas:
+		status.Phase = "Progressing"
+		status.Message = fmt.Sprintf("%d old replicas are pending termination", d.Status.Replicas-d.Status.UpdatedReplicas)
+	case d.Status.AvailableReplicas < d.Status.UpdatedReplicas:
+		status.Phase = "Progressing"
+		status.Message = fmt.Sprintf("%d of %d updated replicas are available", d.Status.AvailableReplicas, d.Status.UpdatedReplicas)
+	default:
+		status.Phase = "Complete"
+		status.Message = "successfully rolled out"
+	}
+
+	return status, nil
+}
+
+// RestartDeployment triggers a rolling restart like `kubectl rollout restart`
+// by stamping the pod template with the current time
+func (c *Client) RestartDeployment(ctx context.Context, namespace, name string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{
+						restartedAtAnnotation: time.Now().Format(time.RFC3339),
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build restart patch: %w", err)
+	}
+
+	_, err = c.Clientset.AppsV1().Deployments(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to restart deployment: %w", err)
+	}
+	return nil
+}
+
+// getOwnedReplicaSets returns the replica sets controlled by the deployment
+func (c *Client) getOwnedReplicaSets(ctx context.Context, d *appsv1.Deployment) ([]appsv1.ReplicaSet, error) {
+	selector, err := metav1.LabelSelectorAsSelector(d.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deployment selector: %w", err)
+	}
+
+	rsList, err := c.Clientset.AppsV1().ReplicaSets(d.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replicasets: %w", err)
+	}
+
+	owned := make([]appsv1.ReplicaSet, 0, len(rsList.Items))
+	for _, rs := range rsList.Items {
+		if ref := metav1.GetControllerOf(&rs); ref != nil && ref.UID == d.UID {
+			owned = append(owned, rs)
+		}
+	}
+
+	return owned, nil
+}
+
+// findRevision picks the replica set for the requested revision (0 = previous)
+func findRevision(replicaSets []appsv1.ReplicaSet, revision, currentRevision int64) (*appsv1.ReplicaSet, error) {
+	var previous *appsv1.ReplicaSet
+	var previousRevision int64
+
+	for i := range replicaSets {
+		rev, err := strconv.ParseInt(replicaSets[i].Annotations[revisionAnnotation], 10, 64)
+		if err != nil {
+			continue
+		}
+		if revision > 0 && rev == revision {
+			return &replicaSets[i], nil
+		}
+		if revision == 0 && rev < currentRevision && rev > previousRevision {
+			previous = &replicaSets[i]
+			previousRevision = rev
+		}
+	}
+
+	if revision > 0 {
+		return nil, fmt.Errorf("%w: %d", ErrRevisionNotFound, revision)
+	}
+	if previous == nil {
+		return nil, fmt.Errorf("%w: no rollout history", ErrRevisionNotFound)
+	}
+	return previous, nil
+}
+
+// buildRollbackPatch builds the JSON patch kubectl uses for `rollout undo`
+func buildRollbackPatch(d *appsv1.Deployment, template *corev1.PodTemplateSpec, rs *appsv1.ReplicaSet) ([]byte, error) {
+	annotations := make(map[string]string, len(d.Annotations))
+	for k, v := range d.Annotations {
+		annotations[k] = v
+	}
+	if cause, ok := rs.Annotations[changeCauseAnnotation]; ok {
+		annotations[changeCauseAnnotation] = cause
+	} else {
+		delete(annotations, changeCauseAnnotation)
+	}
+
+	patch := []map[string]interface{}{
+		// Guard against concurrent edits between our read and the patch
+		{"op": "test", "path": "/metadata/resourceVersion", "value": d.ResourceVersion},
+		{"op": "replace", "path": "/spec/template", "value": template},
+		{"op": "replace", "path": "/metadata/annotations", "value": annotations},
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal rollback patch: %w", err)
+	}
+	return data, nil
+}
+
+func hasProgressDeadlineExceeded(d *appsv1.Deployment) bool {
+	for _, cond := range d.Status.Conditions {
+		if cond.Type == appsv1.DeploymentProgressing && cond.Reason == "ProgressDeadlineExceeded" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,240 @@
+package logarchive
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krzyzao/kub/internal/k8s"
+	"github.com/krzyzao/kub/internal/logs"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// Defaults for KUB_LOG_ARCHIVE_MAX_SIZE (MB) and KUB_LOG_ARCHIVE_MAX_AGE
+	DefaultMaxSizeMB = 1024
+	DefaultMaxAge    = 7 * 24 * time.Hour
+
+	// Containers archived at once per target
+	collectorMaxStreams = 200
+	flushInterval       = 5 * time.Second
+	pruneInterval       = 10 * time.Minute
+	// Wait before tailing a target again after its pod watch failed
+	retryInterval = 30 * time.Second
+)
+
+// Target selects the pods whose logs are archived
+type Target struct {
+	Namespace string
+	Selector  string // empty = every pod of the namespace
+}
+
+// Config is the archive configuration read from the environment
+type Config struct {
+	Targets  []Target
+	MaxBytes int64
+	MaxAge   time.Duration
+}
+
+// ConfigFromEnv reads KUB_LOG_ARCHIVE, a ';'-separated list of "namespace" or
+// "namespace:selector" targets (empty = collector disabled), and the
+// KUB_LOG_ARCHIVE_MAX_SIZE (MB) and KUB_LOG_ARCHIVE_MAX_AGE retention limits
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{MaxBytes: DefaultMaxSizeMB << 20, MaxAge: DefaultMaxAge}
+
+	targets, err := ParseTargets(os.Getenv("KUB_LOG_ARCHIVE"))
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Targets = targets
+
+	if v := os.Getenv("KUB_LOG_ARCHIVE_MAX_SIZE"); v != "" {
+		mb, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || mb < 0 {
+			return cfg, fmt.Errorf("invalid KUB_LOG_ARCHIVE_MAX_SIZE %q", v)
+		}
+		cfg.MaxBytes = mb << 20
+	}
+	if v := os.Getenv("KUB_LOG_ARCHIVE_MAX_AGE"); v != "" {
+		age, err := time.ParseDuration(v)
+		if err != nil || age < 0 {
+			return cfg, fmt.Errorf("invalid KUB_LOG_ARCHIVE_MAX_AGE %q", v)
+		}
+		cfg.MaxAge = age
+	}
+
+	return cfg, nil
+}
+
+// ParseTargets parses a ';'-separated list of "namespace" or
+// "namespace:selector" targets
+func ParseTargets(spec string) ([]Target, error) {
+	var targets []Target
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		namespace, selector, _ := strings.Cut(part, ":")
+		target := Target{Namespace: strings.TrimSpace(namespace)}
+		if target.Namespace == "" {
+			return nil, fmt.Errorf("invalid log archive target %q: namespace is required", part)
+		}
+		if selector = strings.TrimSpace(selector); selector != "" {
+			parsed, err := labels.Parse(selector)
+			if err != nil {
+				return nil, fmt.Errorf("invalid log archive target %q: %w", part, err)
+			}
+			target.Selector = parsed.String()
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// Collector tails the logs of the targeted pods into a Store
+type Collector struct {
+	client  *k8s.Client
+	store   *Store
+	targets []Target
+
+	mu   sync.Mutex
+	last map[string]*time.Time // newest archived line per container, to skip replays
+}
+
+// NewCollector creates a collector for the given targets
+func NewCollector(client *k8s.Client, store *Store, targets []Target) *Collector {
+	return &Collector{
+		client:  client,
+		store:   store,
+		targets: targets,
+		last:    make(map[string]*time.Time),
+	}
+}
+
+// Run collects logs and applies retention until ctx is cancelled
+func (c *Collector) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, target := range c.targets {
+		wg.Add(1)
+		go func(target Target) {
+			defer wg.Done()
+			c.runTarget(ctx, target)
+		}(target)
+	}
+
+	flush := time.NewTicker(flushInterval)
+	defer flush.Stop()
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+
+	c.prune()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			if err := c.store.Close(); err != nil {
+				log.Printf("Failed to close log archive: %v", err)
+			}
+			return
+		case <-flush.C:
+			c.store.Flush()
+		case <-prune.C:
+			c.prune()
+		}
+	}
+}
+
+// runTarget tails one target, starting again after failures
+func (c *Collector) runTarget(ctx context.Context, target Target) {
+	log.Printf("Archiving logs of %s (selector %q)", target.Namespace, target.Selector)
+
+	for {
+		// Lines are filed under the context the tailer was started in
+		_, currentContext := c.client.GetContexts()
+
+		lines := make(chan k8s.LogLine, 256)
+		tailer := c.client.NewLogTailer(target.Namespace, k8s.LogTailOptions{
+			LabelSelector: target.Selector,
+			Timestamps:    true,
+			MaxStreams:    collectorMaxStreams,
+		}, lines, func(event k8s.LogTailEvent) {
+			// Events must not block the tailer; a line still buffered for the
+			// container just starts a new segment that Prune closes when idle
+			if event.Type == "detach" {
+				go c.detach(currentContext, target.Namespace, event.Pod, event.Container)
+			}
+		})
+
+		done := make(chan error, 1)
+		go func() {
+			done <- tailer.Run(ctx)
+			close(lines)
+		}()
+
+		for line := range lines {
+			c.archive(currentContext, target.Namespace, line)
+		}
+
+		if err := <-done; err != nil {
+			log.Printf("Log archive collector for %s: %v", target.Namespace, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// archive stores a timestamped line unless it was archived before, which
+// happens when a container's whole log is read again after a restart of kub
+func (c *Collector) archive(context, namespace string, line k8s.LogLine) {
+	ts, text := logs.SplitTimestamp(line.Text)
+	if ts == nil {
+		return
+	}
+
+	key := strings.Join([]string{context, namespace, line.Pod, line.Container}, "/")
+	c.mu.Lock()
+	last, ok := c.last[key]
+	if !ok {
+		last = c.store.LastTimestamp(context, namespace, line.Pod, line.Container)
+	}
+	if last != nil && !ts.After(*last) {
+		c.last[key] = last
+		c.mu.Unlock()
+		return
+	}
+	c.last[key] = ts
+	c.mu.Unlock()
+
+	if err := c.store.Append(context, namespace, line.Pod, line.Container, *ts, text); err != nil {
+		log.Printf("Failed to archive log line of %s/%s: %v", namespace, line.Pod, err)
+	}
+}
+
+// detach closes the archive segment of a container whose log stream ended
+func (c *Collector) detach(context, namespace, pod, container string) {
+	if err := c.store.CloseContainer(context, namespace, pod, container); err != nil {
+		log.Printf("Failed to close log archive segment of %s/%s: %v", namespace, pod, err)
+	}
+}
+
+func (c *Collector) prune() {
+	// Forget containers of deleted pods; live ones are looked up again
+	c.mu.Lock()
+	c.last = make(map[string]*time.Time)
+	c.mu.Unlock()
+
+	if err := c.store.Prune(time.Now()); err != nil {
+		log.Printf("Log archive retention failed: %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package logarchive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/krzyzao/kub/internal/logs"
+)
+
+// truncatedReader ends cleanly at the end of a segment that is still being
+// written, which has no gzip trailer yet
+type truncatedReader struct {
+	r io.Reader
+}
+
+func (t truncatedReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func newSegmentReader(f *os.File) (io.Reader, error) {
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			// Created but nothing flushed yet
+			return eofReader{}, nil
+		}
+		return nil, err
+	}
+	return truncatedReader{r: gz}, nil
+}
+
+type eofReader struct{}
+
+func (eofReader) Read([]byte) (int, error) { return 0, io.EOF }
+
+// archiveReader reads segments one after another, skipping lines before since
+type archiveReader struct {
+	files   []*os.File
+	since   *time.Time
+	current *bufio.Reader
+	pending []byte
+}
+
+func newArchiveReader(files []*os.File, since *time.Time) *archiveReader {
+	return &archiveReader{files: files, since: since}
+}
+
+func (a *archiveReader) Read(p []byte) (int, error) {
+	for len(a.pending) == 0 {
+		if a.current == nil {
+			if len(a.files) == 0 {
+				return 0, io.EOF
+			}
+			r, err := newSegmentReader(a.files[0])
+			if err != nil {
+				// A damaged segment must not hide the following ones
+				a.nextFile()
+				continue
+			}
+			a.current = bufio.NewReader(r)
+		}
+
+		line, err := a.current.ReadBytes('\n')
+		if len(line) > 0 && a.keep(line) {
+			a.pending = line
+		}
+		if err != nil {
+			a.nextFile()
+		}
+	}
+
+	n := copy(p, a.pending)
+	a.pending = a.pending[n:]
+	return n, nil
+}
+
+// keep drops lines before since; later segments start after it anyway
+func (a *archiveReader) keep(line []byte) bool {
+	if a.since == nil {
+		return true
+	}
+	ts, _ := logs.SplitTimestamp(string(line))
+	if ts != nil && ts.Before(*a.since) {
+		return false
+	}
+	a.since = nil
+	return true
+}
+
+func (a *archiveReader) nextFile() {
+	a.files[0].Close()
+	a.files = a.files[1:]
+	a.current = nil
+}
+
+// Close closes the segments not read to the end
+func (a *archiveReader) Close() error {
+	for _, f := range a.files {
+		f.Close()
+	}
+	a.files = nil
+	return nil
+}
@@ -0,0 +1,462 @@
+// Package logarchive keeps container logs on disk after their pods are gone.
+// Lines are stored with their kubelet timestamp in gzip segments laid out as
+// <context>/<namespace>/<pod>/<container>/<first line unix nanos>.log.gz, so
+// the directory tree is the index by pod, container and time.
+package logarchive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krzyzao/kub/internal/logs"
+	"github.com/krzyzao/kub/internal/models"
+)
+
+const (
+	segmentSuffix = ".log.gz"
+	// A segment is closed and a new one started after this much uncompressed
+	// data or time, so retention can drop old data in small steps
+	maxSegmentBytes = 16 << 20
+	maxSegmentAge   = time.Hour
+	// A segment not written to for this long is closed by Prune, so it can be
+	// pruned and its file handle is not held for containers that stopped logging
+	maxSegmentIdle = 10 * time.Minute
+)
+
+// ErrNotFound is returned when nothing is archived for a container
+var ErrNotFound = errors.New("no archived logs found")
+
+// unsafePathChars matches characters not allowed in an archive path segment
+var unsafePathChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// Store is an on-disk log archive with size and age retention
+type Store struct {
+	mu       sync.Mutex
+	root     string
+	maxBytes int64         // total compressed size; 0 = unlimited
+	maxAge   time.Duration // 0 = unlimited
+	open     map[string]*segment
+}
+
+// segment is a segment file being written
+type segment struct {
+	path    string
+	file    *os.File
+	gz      *gzip.Writer
+	first   time.Time
+	last    time.Time
+	written int64
+	touched time.Time // wall clock time of the last write
+	dirty   bool      // written to since the last flush
+}
+
+// segmentInfo describes a segment file on disk
+type segmentInfo struct {
+	path  string
+	first time.Time
+	last  time.Time // the file's mtime is set to the last line's time
+	size  int64
+}
+
+// NewStore creates a store rooted at dir
+func NewStore(dir string, maxBytes int64, maxAge time.Duration) *Store {
+	return &Store{
+		root:     dir,
+		maxBytes: maxBytes,
+		maxAge:   maxAge,
+		open:     make(map[string]*segment),
+	}
+}
+
+// Append archives one log line taken at ts. line must not contain the
+// kubelet timestamp or the trailing newline.
+func (s *Store) Append(context, namespace, pod, container string, ts time.Time, line string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := s.dir(context, namespace, pod, container)
+	seg := s.open[dir]
+	if seg != nil && (seg.written >= maxSegmentBytes || ts.Sub(seg.first) >= maxSegmentAge) {
+		if err := seg.close(); err != nil {
+			return err
+		}
+		delete(s.open, dir)
+		seg = nil
+	}
+
+	if seg == nil {
+		var err error
+		if seg, err = createSegment(dir, ts); err != nil {
+			return err
+		}
+		s.open[dir] = seg
+	}
+
+	n, err := fmt.Fprintf(seg.gz, "%s %s\n", ts.UTC().Format(time.RFC3339Nano), line)
+	if err != nil {
+		return fmt.Errorf("failed to write archive segment: %w", err)
+	}
+	seg.written += int64(n)
+	seg.last = ts
+	seg.touched = time.Now()
+	seg.dirty = true
+	return nil
+}
+
+// Flush makes everything appended so far readable
+func (s *Store) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, seg := range s.open {
+		seg.flush()
+	}
+}
+
+// CloseContainer closes the segment being written for a container, if any.
+// It is called when the container's log stream ends.
+func (s *Store) CloseContainer(context, namespace, pod, container string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := s.dir(context, namespace, pod, container)
+	seg := s.open[dir]
+	if seg == nil {
+		return nil
+	}
+	delete(s.open, dir)
+	return seg.close()
+}
+
+// Close closes all open segments
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var firstErr error
+	for dir, seg := range s.open {
+		if err := seg.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(s.open, dir)
+	}
+	return firstErr
+}
+
+// LastTimestamp returns the time of the newest line archived for a container
+func (s *Store) LastTimestamp(context, namespace, pod, container string) *time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := s.dir(context, namespace, pod, container)
+	if seg := s.open[dir]; seg != nil {
+		last := seg.last
+		return &last
+	}
+
+	segments := listSegments(dir)
+	if len(segments) == 0 {
+		return nil
+	}
+
+	// The mtime is only exact for cleanly closed segments, so read the newest one
+	f, err := os.Open(segments[len(segments)-1].path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var last *time.Time
+	reader, err := newSegmentReader(f)
+	if err != nil {
+		return nil
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if ts, _ := logs.SplitTimestamp(scanner.Text()); ts != nil {
+			last = ts
+		}
+	}
+	return last
+}
+
+// Containers returns the names of the containers archived for a pod
+func (s *Store) Containers(context, namespace, pod string) []string {
+	entries, err := os.ReadDir(s.dir(context, namespace, pod))
+	if err != nil {
+		return nil
+	}
+
+	var containers []string
+	for _, e := range entries {
+		if e.IsDir() {
+			containers = append(containers, e.Name())
+		}
+	}
+	return containers
+}
+
+// Open returns the archived, timestamped log lines of a container at or
+// after since (nil = all), oldest first. An empty container selects the only
+// archived container of the pod.
+func (s *Store) Open(context, namespace, pod, container string, since *time.Time) (io.ReadCloser, error) {
+	if container == "" {
+		containers := s.Containers(context, namespace, pod)
+		switch len(containers) {
+		case 0:
+			return nil, ErrNotFound
+		case 1:
+			container = containers[0]
+		default:
+			return nil, fmt.Errorf("a container name must be specified, archived containers: %s", strings.Join(containers, ", "))
+		}
+	}
+
+	dir := s.dir(context, namespace, pod, container)
+
+	s.mu.Lock()
+	if seg := s.open[dir]; seg != nil {
+		seg.flush()
+	}
+	s.mu.Unlock()
+
+	segments := listSegments(dir)
+	if len(segments) == 0 {
+		return nil, ErrNotFound
+	}
+
+	var files []*os.File
+	for _, info := range segments {
+		if since != nil && info.last.Before(*since) {
+			continue
+		}
+		f, err := os.Open(info.path)
+		if err != nil {
+			continue // removed by retention in the meantime
+		}
+		files = append(files, f)
+	}
+	return newArchiveReader(files, since), nil
+}
+
+// List describes the archived containers of a namespace (empty = all) in the
+// given context
+func (s *Store) List(context, namespace string) []models.ArchivedContainer {
+	s.Flush()
+
+	base := filepath.Join(s.root, safeSegment(context))
+	namespaces := []string{namespace}
+	if namespace == "" {
+		namespaces = subdirs(base)
+	}
+
+	result := []models.ArchivedContainer{}
+	for _, ns := range namespaces {
+		for _, pod := range subdirs(filepath.Join(base, safeSegment(ns))) {
+			for _, container := range subdirs(filepath.Join(base, safeSegment(ns), pod)) {
+				segments := listSegments(filepath.Join(base, safeSegment(ns), pod, container))
+				if len(segments) == 0 {
+					continue
+				}
+				archived := models.ArchivedContainer{
+					Namespace: ns,
+					Pod:       pod,
+					Container: container,
+					First:     segments[0].first,
+					Last:      segments[len(segments)-1].last,
+					Segments:  len(segments),
+				}
+				for _, seg := range segments {
+					archived.Bytes += seg.size
+				}
+				result = append(result, archived)
+			}
+		}
+	}
+	return result
+}
+
+// Prune applies retention: segments older than the maximum age are removed,
+// then the oldest segments until the archive fits the size limit. Idle
+// segments are closed first; segments still being written are kept.
+func (s *Store) Prune(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for dir, seg := range s.open {
+		if now.Sub(seg.touched) < maxSegmentIdle {
+			continue
+		}
+		delete(s.open, dir)
+		if err := seg.close(); err != nil {
+			return err
+		}
+	}
+
+	var segments []segmentInfo
+	err := filepath.WalkDir(s.root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, segmentSuffix) {
+			return nil
+		}
+		if seg := s.open[filepath.Dir(path)]; seg != nil && seg.path == path {
+			return nil
+		}
+		if info, ok := statSegment(path); ok {
+			segments = append(segments, info)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan log archive: %w", err)
+	}
+
+	sort.Slice(segments, func(i, j int) bool { return segments[i].last.Before(segments[j].last) })
+
+	var total int64
+	for _, seg := range segments {
+		total += seg.size
+	}
+	for _, seg := range s.open {
+		if info, err := seg.file.Stat(); err == nil {
+			total += info.Size()
+		}
+	}
+
+	for _, seg := range segments {
+		expired := s.maxAge > 0 && now.Sub(seg.last) > s.maxAge
+		oversize := s.maxBytes > 0 && total > s.maxBytes
+		if !expired && !oversize {
+			break
+		}
+		if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove archive segment: %w", err)
+		}
+		total -= seg.size
+		removeEmptyDirs(filepath.Dir(seg.path), s.root)
+	}
+	return nil
+}
+
+func (s *Store) dir(context, namespace, pod string, container ...string) string {
+	parts := []string{s.root, safeSegment(context), safeSegment(namespace), safeSegment(pod)}
+	for _, c := range container {
+		parts = append(parts, safeSegment(c))
+	}
+	return filepath.Join(parts...)
+}
+
+func createSegment(dir string, first time.Time) (*segment, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	path := filepath.Join(dir, strconv.FormatInt(first.UnixNano(), 10)+segmentSuffix)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive segment: %w", err)
+	}
+
+	return &segment{path: path, file: f, gz: gzip.NewWriter(f), first: first, last: first, touched: time.Now()}, nil
+}
+
+func (seg *segment) flush() {
+	if seg.dirty {
+		seg.gz.Flush()
+		seg.dirty = false
+	}
+}
+
+// close finishes the segment and stamps its mtime with the last line's time
+func (seg *segment) close() error {
+	if err := seg.gz.Close(); err != nil {
+		seg.file.Close()
+		return fmt.Errorf("failed to finish archive segment: %w", err)
+	}
+	if err := seg.file.Close(); err != nil {
+		return fmt.Errorf("failed to close archive segment: %w", err)
+	}
+	return os.Chtimes(seg.path, seg.last, seg.last)
+}
+
+// listSegments returns a container's segments, oldest first
+func listSegments(dir string) []segmentInfo {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var segments []segmentInfo
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), segmentSuffix) {
+			continue
+		}
+		if info, ok := statSegment(filepath.Join(dir, e.Name())); ok {
+			segments = append(segments, info)
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].first.Before(segments[j].first) })
+	return segments
+}
+
+func statSegment(path string) (segmentInfo, bool) {
+	nanos, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(path), segmentSuffix), 10, 64)
+	if err != nil {
+		return segmentInfo{}, false
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return segmentInfo{}, false
+	}
+	return segmentInfo{path: path, first: time.Unix(0, nanos), last: stat.ModTime(), size: stat.Size()}, true
+}
+
+func subdirs(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+// removeEmptyDirs removes dir and its parents up to (excluding) root while empty
+func removeEmptyDirs(dir, root string) {
+	for dir != root && strings.HasPrefix(dir, root) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// safeSegment makes a value usable as a single path element
+func safeSegment(s string) string {
+	s = unsafePathChars.ReplaceAllString(s, "_")
+	if s == "" || s == "." || s == ".." {
+		return "_"
+	}
+	return s
+}
@@ -0,0 +1,300 @@
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/krzyzao/kub/internal/models"
+)
+
+// Supported structured log formats
+const (
+	FormatAuto   = "auto" // try JSON, then logfmt
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+	FormatText   = "text" // line could not be parsed
+)
+
+// Normalized levels in increasing severity
+var levelOrder = []string{"trace", "debug", "info", "warn", "error", "fatal"}
+
+// Common spellings of levels mapped to the normalized name
+var levelAliases = map[string]string{
+	"trace": "trace", "trc": "trace",
+	"debug": "debug", "dbg": "debug",
+	"info": "info", "inf": "info", "information": "info", "notice": "info",
+	"warn": "warn", "warning": "warn", "wrn": "warn",
+	"error": "error", "err": "error", "eror": "error",
+	"fatal": "fatal", "ftl": "fatal", "critical": "fatal", "crit": "fatal",
+	"panic": "fatal", "dpanic": "fatal", "emergency": "fatal", "alert": "fatal",
+}
+
+// Keys checked, in order, for the well-known parts of an entry
+var (
+	levelKeys     = []string{"level", "lvl", "severity", "loglevel", "log.level"}
+	messageKeys   = []string{"msg", "message", "log"}
+	timestampKeys = []string{"ts", "time", "timestamp", "@timestamp", "t"}
+)
+
+// Filter selects parsed entries
+type Filter struct {
+	MinLevel string            // normalized level; empty = no level filter
+	Fields   map[string]string // field must be present with this (string) value
+}
+
+// Processor parses log lines and applies a filter to them
+type Processor struct {
+	Format string
+	Filter Filter
+}
+
+// NewProcessor validates the parse format, minimum level and "key=value"
+// field filters. It returns nil if none of them is set.
+func NewProcessor(format, minLevel string, fields []string) (*Processor, error) {
+	if format == "" && minLevel == "" && len(fields) == 0 {
+		return nil, nil
+	}
+
+	p := &Processor{Format: format, Filter: Filter{Fields: make(map[string]string)}}
+	switch format {
+	case "":
+		p.Format = FormatAuto
+	case FormatAuto, FormatJSON, FormatLogfmt:
+	default:
+		return nil, fmt.Errorf("unsupported format %q (auto, json or logfmt)", format)
+	}
+
+	if minLevel != "" {
+		level, ok := levelAliases[strings.ToLower(minLevel)]
+		if !ok {
+			return nil, fmt.Errorf("unknown level %q", minLevel)
+		}
+		p.Filter.MinLevel = level
+	}
+
+	for _, f := range fields {
+		key, value, ok := strings.Cut(f, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid field filter %q (expected key=value)", f)
+		}
+		p.Filter.Fields[key] = value
+	}
+
+	return p, nil
+}
+
+// Process parses a line and reports whether it passes the filter. With
+// timestamped set, a leading kubelet timestamp is removed first and used when
+// the entry has no timestamp of its own.
+func (p *Processor) Process(line string, timestamped bool) (models.LogEntry, bool) {
+	var prefix *time.Time
+	if timestamped {
+		prefix, line = SplitTimestamp(line)
+	}
+
+	entry := Parse(line, p.Format)
+	if entry.Timestamp == nil {
+		entry.Timestamp = prefix
+	}
+	return entry, p.Filter.Match(entry)
+}
+
+// Match reports whether an entry passes the filter. Entries without a
+// recognizable level pass the level filter so continuation lines (e.g. stack
+// traces) are not lost.
+func (f Filter) Match(e models.LogEntry) bool {
+	if f.MinLevel != "" && e.Level != "" && levelRank(e.Level) < levelRank(f.MinLevel) {
+		return false
+	}
+	for key, want := range f.Fields {
+		got, ok := e.Fields[key]
+		if !ok || fmt.Sprint(got) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse extracts timestamp, level, message and fields from a JSON or logfmt
+// line. Lines that cannot be parsed are returned as text with the whole line as message.
+func Parse(line string, format string) models.LogEntry {
+	var fields map[string]interface{}
+	parsedFormat := FormatText
+
+	trimmed := strings.TrimSpace(line)
+	if (format == FormatAuto || format == FormatJSON) && strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal([]byte(trimmed), &fields); err == nil {
+			parsedFormat = FormatJSON
+		}
+	}
+	if parsedFormat == FormatText && (format == FormatAuto || format == FormatLogfmt) {
+		if f, ok := parseLogfmt(trimmed); ok {
+			fields = f
+			parsedFormat = FormatLogfmt
+		}
+	}
+
+	if parsedFormat == FormatText {
+		return models.LogEntry{Format: FormatText, Message: line}
+	}
+
+	entry := models.LogEntry{Format: parsedFormat, Fields: fields}
+	if v, key := lookupField(fields, levelKeys); key != "" {
+		entry.Level = normalizeLevel(v)
+	}
+	if v, key := lookupField(fields, messageKeys); key != "" {
+		entry.Message = fmt.Sprint(v)
+	}
+	if v, key := lookupField(fields, timestampKeys); key != "" {
+		entry.Timestamp = parseTimestamp(v)
+	}
+
+	return entry
+}
+
+func lookupField(fields map[string]interface{}, keys []string) (interface{}, string) {
+	for _, k := range keys {
+		if v, ok := fields[k]; ok {
+			return v, k
+		}
+	}
+	// Nested objects, e.g. {"log": {"level": "info"}} for "log.level"
+	for _, k := range keys {
+		parent, child, ok := strings.Cut(k, ".")
+		if !ok {
+			continue
+		}
+		if nested, ok := fields[parent].(map[string]interface{}); ok {
+			if v, ok := nested[child]; ok {
+				return v, k
+			}
+		}
+	}
+	return nil, ""
+}
+
+// normalizeLevel maps level names and bunyan/pino numeric levels to levelOrder
+func normalizeLevel(v interface{}) string {
+	switch level := v.(type) {
+	case float64:
+		switch {
+		case level >= 60:
+			return "fatal"
+		case level >= 50:
+			return "error"
+		case level >= 40:
+			return "warn"
+		case level >= 30:
+			return "info"
+		case level >= 20:
+			return "debug"
+		default:
+			return "trace"
+		}
+	case string:
+		if n, err := strconv.Atoi(level); err == nil {
+			return normalizeLevel(float64(n))
+		}
+		if normalized, ok := levelAliases[strings.ToLower(level)]; ok {
+			return normalized
+		}
+		return strings.ToLower(level)
+	}
+	return ""
+}
+
+func levelRank(level string) int {
+	for i, l := range levelOrder {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseTimestamp accepts RFC3339 strings and Unix times in seconds or milliseconds
+func parseTimestamp(v interface{}) *time.Time {
+	switch ts := v.(type) {
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999Z07:00", "2006-01-02T15:04:05.999999999"} {
+			if t, err := time.Parse(layout, ts); err == nil {
+				return &t
+			}
+		}
+		if f, err := strconv.ParseFloat(ts, 64); err == nil {
+			return parseTimestamp(f)
+		}
+	case float64:
+		var t time.Time
+		// Anything past year ~5138 in seconds is really milliseconds
+		if ts > 1e11 {
+			t = time.UnixMilli(int64(ts))
+		} else {
+			sec, frac := math.Modf(ts)
+			t = time.Unix(int64(sec), int64(frac*1e9))
+		}
+		return &t
+	}
+	return nil
+}
+
+// parseLogfmt parses key=value pairs with optionally quoted values. A line only
+// counts as logfmt if every token is a pair and there are at least two pairs.
+func parseLogfmt(line string) (map[string]interface{}, bool) {
+	fields := make(map[string]interface{})
+	pairs := 0
+
+	for i := 0; i < len(line); {
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+		if i >= len(line) {
+			break
+		}
+
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' {
+			i++
+		}
+		key := line[start:i]
+		if key == "" || i >= len(line) || line[i] != '=' {
+			return nil, false
+		}
+		i++ // skip '='
+
+		var value string
+		if i < len(line) && line[i] == '"' {
+			end := i + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, false
+			}
+			unquoted, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				return nil, false
+			}
+			value = unquoted
+			i = end + 1
+		} else {
+			start = i
+			for i < len(line) && line[i] != ' ' {
+				i++
+			}
+			value = line[start:i]
+		}
+
+		fields[key] = value
+		pairs++
+	}
+
+	return fields, pairs >= 2
+}
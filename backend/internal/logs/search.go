@@ -0,0 +1,159 @@
+// Package logs scans and interprets container log output independently of
+// where it comes from (live streams, finished logs or archives).
+package logs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/krzyzao/kub/internal/models"
+)
+
+// Query describes what to look for in a log
+type Query struct {
+	Pattern       string
+	Regex         bool // treat Pattern as a regular expression instead of a substring
+	CaseSensitive bool
+	Context       int        // lines to include before and after each match
+	Limit         int        // stop after this many matches; 0 = no limit
+	Until         *time.Time // stop at the first line newer than this
+	// Input lines start with an RFC3339 timestamp (PodLogOptions.Timestamps);
+	// required for Until and for timestamps in the results
+	Timestamped     bool
+	StripTimestamps bool       // remove the timestamp prefix from result text
+	Processor       *Processor // optional: only lines passing its filter can match
+}
+
+// Compile returns the matcher for the query pattern
+func (q Query) Compile() (*regexp.Regexp, error) {
+	pattern := q.Pattern
+	if !q.Regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if !q.CaseSensitive {
+		pattern = "(?i)" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	return re, nil
+}
+
+// passes applies the optional level/field filter to a line without its timestamp
+func (q Query) passes(message string) bool {
+	if q.Processor == nil {
+		return true
+	}
+	_, keep := q.Processor.Process(message, false)
+	return keep
+}
+
+type numberedLine struct {
+	number    int
+	timestamp *time.Time
+	text      string
+}
+
+// Search scans r line by line and calls emit for every match and context line
+// as soon as it is known, followed by a summary record. Scanning stops early
+// at the match limit, at Until, or when emit returns an error.
+func Search(r io.Reader, q Query, emit func(models.LogSearchResult) error) error {
+	re, err := q.Compile()
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(r)
+	before := make([]numberedLine, 0, q.Context)
+	lastEmitted := 0
+	afterRemaining := 0
+	summary := models.LogSearchResult{Type: "summary"}
+
+	send := func(kind string, l numberedLine) error {
+		lastEmitted = l.number
+		return emit(models.LogSearchResult{Type: kind, LineNumber: l.number, Timestamp: l.timestamp, Text: l.text})
+	}
+
+	for number := 1; ; number++ {
+		raw, readErr := reader.ReadString('\n')
+		if raw == "" && readErr != nil {
+			if readErr != io.EOF {
+				return fmt.Errorf("failed to read logs: %w", readErr)
+			}
+			break
+		}
+		raw = strings.TrimRight(raw, "\r\n")
+		summary.LinesScanned = number
+
+		// Match against the message, not the timestamp prefix
+		line := numberedLine{number: number, text: raw}
+		message := raw
+		if q.Timestamped {
+			line.timestamp, message = SplitTimestamp(raw)
+			if q.StripTimestamps {
+				line.text = message
+			}
+			if q.Until != nil && line.timestamp != nil && line.timestamp.After(*q.Until) {
+				summary.LinesScanned = number - 1
+				break
+			}
+		}
+
+		switch {
+		case re.MatchString(message) && q.passes(message):
+			for _, b := range before {
+				if b.number > lastEmitted {
+					if err := send("context", b); err != nil {
+						return err
+					}
+				}
+			}
+			before = before[:0]
+			if err := send("match", line); err != nil {
+				return err
+			}
+			summary.Matches++
+			afterRemaining = q.Context
+		case afterRemaining > 0:
+			if err := send("context", line); err != nil {
+				return err
+			}
+			afterRemaining--
+		case q.Context > 0:
+			if len(before) == q.Context {
+				before = append(before[:0], before[1:]...)
+			}
+			before = append(before, line)
+		}
+
+		if q.Limit > 0 && summary.Matches >= q.Limit && afterRemaining == 0 {
+			summary.LimitReached = true
+			break
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	return emit(summary)
+}
+
+// SplitTimestamp splits the RFC3339 timestamp the kubelet prefixes to lines when
+// timestamps are requested. It returns nil and the whole line if there is none.
+func SplitTimestamp(line string) (*time.Time, string) {
+	prefix, rest, found := strings.Cut(line, " ")
+	if !found {
+		prefix, rest = line, ""
+	}
+	ts, err := time.Parse(time.RFC3339Nano, prefix)
+	if err != nil {
+		return nil, line
+	}
+	return &ts, rest
+}
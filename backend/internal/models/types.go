@@ -81,6 +81,9 @@ type Container struct {
 	SecurityContext *SecurityContext     `json:"securityContext,omitempty"`
 	Command         []string        `json:"command,omitempty"`
 	Args            []string        `json:"args,omitempty"`
+
+	// Reason of the previous termination, e.g. OOMKilled
+	LastTerminationReason string `json:"lastTerminationReason,omitempty"`
 }
 
 // VolumeMount represents a volume mount in a container
@@ -156,6 +159,7 @@ type Node struct {
 	CPUPercent         float64           `json:"cpuPercent"`
 	MemoryPercent      float64           `json:"memoryPercent"`
 	PodCount           int               `json:"podCount"`
+	PodAllocatable     int64             `json:"podAllocatable"`
 	Age                string            `json:"age"`
 	CreatedAt          time.Time         `json:"createdAt"`
 	Conditions         []NodeCondition   `json:"conditions"`
@@ -166,6 +170,20 @@ type Node struct {
 	Addresses          []NodeAddress     `json:"addresses,omitempty"`
 	Images             []string          `json:"images,omitempty"`
 	PodCIDR            string            `json:"podCIDR,omitempty"`
+
+	// Reserved by non-terminated pods; filled by GetNodesWithAllocation
+	CPURequests          int64     `json:"cpuRequests"`          // millicores
+	CPULimits            int64     `json:"cpuLimits"`            // millicores
+	MemoryRequests       int64     `json:"memoryRequests"`       // bytes
+	MemoryLimits         int64     `json:"memoryLimits"`         // bytes
+	CPURequestPercent    float64   `json:"cpuRequestPercent"`    // of allocatable
+	MemoryRequestPercent float64   `json:"memoryRequestPercent"` // of allocatable
+	CPUOvercommit        float64   `json:"cpuOvercommit"`        // limits / allocatable
+	MemoryOvercommit     float64   `json:"memoryOvercommit"`     // limits / allocatable
+	CPUSchedulable       int64     `json:"cpuSchedulable"`       // allocatable - requests, millicores
+	MemorySchedulable    int64     `json:"memorySchedulable"`    // allocatable - requests, bytes
+	PodsSchedulable      int64     `json:"podsSchedulable"`
+	Pods                 []NodePod `json:"pods,omitempty"` // largest requests first
 }
 
 // Taint represents a node taint
@@ -222,10 +240,27 @@ type NodeMetrics struct {
 
 // PodMetrics represents metrics for a single pod
 type PodMetrics struct {
-	Name        string `json:"name"`
-	Namespace   string `json:"namespace"`
-	CPUUsage    int64  `json:"cpuUsage"`    // millicores
-	MemoryUsage int64  `json:"memoryUsage"` // bytes
+	Name        string             `json:"name"`
+	Namespace   string             `json:"namespace"`
+	CPUUsage    int64              `json:"cpuUsage"`    // millicores
+	MemoryUsage int64              `json:"memoryUsage"` // bytes
+	Containers  []ContainerMetrics `json:"containers,omitempty"`
+}
+
+// ContainerMetrics is the usage of one container against its requests and
+// limits. Unset requests and limits are 0 and have no percentage.
+type ContainerMetrics struct {
+	Name              string  `json:"name"`
+	CPUUsage          int64   `json:"cpuUsage"`    // millicores
+	MemoryUsage       int64   `json:"memoryUsage"` // bytes
+	CPURequest        int64   `json:"cpuRequest"`  // millicores
+	CPULimit          int64   `json:"cpuLimit"`    // millicores
+	MemoryRequest     int64   `json:"memoryRequest"`
+	MemoryLimit       int64   `json:"memoryLimit"`
+	CPURequestPercent float64 `json:"cpuRequestPercent,omitempty"`
+	CPULimitPercent   float64 `json:"cpuLimitPercent,omitempty"`
+	MemRequestPercent float64 `json:"memRequestPercent,omitempty"`
+	MemLimitPercent   float64 `json:"memLimitPercent,omitempty"`
 }
 
 // ClusterSummary represents an overview of the cluster
@@ -275,6 +310,43 @@ type DeploymentCondition struct {
 	Message            string    `json:"message,omitempty"`
 }
 
+// DeploymentRevision represents one entry of a deployment's rollout history
+type DeploymentRevision struct {
+	Revision    int64     `json:"revision"`
+	ReplicaSet  string    `json:"replicaSet"`
+	Images      []string  `json:"images"`
+	ChangeCause string    `json:"changeCause,omitempty"`
+	Replicas    int32     `json:"replicas"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Current     bool      `json:"current"`
+}
+
+// RollbackResult represents the outcome (or preview) of a deployment rollback
+type RollbackResult struct {
+	Namespace    string `json:"namespace"`
+	Name         string `json:"name"`
+	FromRevision int64  `json:"fromRevision"`
+	ToRevision   int64  `json:"toRevision"`
+	DryRun       bool   `json:"dryRun"`
+	Changed      bool   `json:"changed"` // false when the template already matches the target revision
+	Diff         string `json:"diff"`    // unified diff of the pod template
+}
+
+// RolloutStatus represents the progress of a deployment rollout
+type RolloutStatus struct {
+	Namespace               string `json:"namespace"`
+	Name                    string `json:"name"`
+	Revision                string `json:"revision"`
+	Phase                   string `json:"phase"` // Pending, Progressing, Complete, Failed
+	Message                 string `json:"message"`
+	Replicas                int32  `json:"replicas"`
+	UpdatedReplicas         int32  `json:"updatedReplicas"`
+	ReadyReplicas           int32  `json:"readyReplicas"`
+	AvailableReplicas       int32  `json:"availableReplicas"`
+	ProgressDeadlineSeconds int64  `json:"progressDeadlineSeconds"`
+	Timestamp               int64  `json:"timestamp"`
+}
+
 // Service represents a Kubernetes service
 type Service struct {
 	Name            string            `json:"name"`
@@ -305,12 +377,13 @@ type ServicePort struct {
 
 // ConfigMap represents a Kubernetes configmap
 type ConfigMap struct {
-	Name        string            `json:"name"`
-	Namespace   string            `json:"namespace"`
-	DataCount   int               `json:"dataCount"`
-	Keys        []string          `json:"keys"`
-	Age         string            `json:"age"`
-	CreatedAt   time.Time         `json:"createdAt"`
+	Name            string    `json:"name"`
+	Namespace       string    `json:"namespace"`
+	DataCount       int       `json:"dataCount"`
+	Keys            []string  `json:"keys"`
+	Age             string    `json:"age"`
+	CreatedAt       time.Time `json:"createdAt"`
+	ResourceVersion string    `json:"resourceVersion"` // pass back when updating
 	// Extended fields for describe
 	Data        map[string]string `json:"data,omitempty"`
 	BinaryData  map[string]string `json:"binaryData,omitempty"`
@@ -368,3 +441,343 @@ type EndpointPort struct {
 	Port     int32  `json:"port"`
 	Protocol string `json:"protocol"`
 }
+
+// ExecSession represents the audit record of an interactive exec session
+type ExecSession struct {
+	ID        string     `json:"id"`
+	Namespace string     `json:"namespace"`
+	Pod       string     `json:"pod"`
+	Container string     `json:"container"`
+	Command   []string   `json:"command"`
+	ClientIP  string     `json:"clientIP"`
+	StartedAt time.Time  `json:"startedAt"`
+	EndedAt   *time.Time `json:"endedAt,omitempty"`
+	ExitCode  *int       `json:"exitCode,omitempty"`
+	EndReason string     `json:"endReason,omitempty"` // exited, client closed, idle timeout, error
+	BytesIn   int64      `json:"bytesIn"`             // stdin bytes sent to the container
+	BytesOut  int64      `json:"bytesOut"`            // stdout/stderr bytes sent to the client
+}
+
+// PortForward represents an active port-forward managed by the server
+type PortForward struct {
+	ID         string    `json:"id"`
+	Namespace  string    `json:"namespace"`
+	Kind       string    `json:"kind"` // pod, service
+	Name       string    `json:"name"`
+	Pod        string    `json:"pod,omitempty"` // backing pod currently forwarded to
+	LocalPort  int       `json:"localPort"`
+	RemotePort int       `json:"remotePort"` // pod or service port as requested
+	TargetPort int       `json:"targetPort,omitempty"`
+	Status     string    `json:"status"` // Starting, Active, Reconnecting, Stopped
+	Error      string    `json:"error,omitempty"`
+	Restarts   int       `json:"restarts"`
+	StartedAt  time.Time `json:"startedAt"`
+}
+
+// DebugSession represents an ephemeral debug container ready for a terminal session
+type DebugSession struct {
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Image     string `json:"image"`
+	Target    string `json:"target,omitempty"`
+	ExecURL   string `json:"execUrl"` // /ws/exec endpoint attached to the debug container
+	LogsURL   string `json:"logsUrl"` // /ws/logs endpoint for the debug container
+}
+
+// ConfirmationRequired is returned when a destructive action needs a second,
+// confirmed request carrying the token
+type ConfirmationRequired struct {
+	Action    string    `json:"action"`
+	Targets   []string  `json:"targets"` // namespace/name of every affected object
+	Token     string    `json:"confirmationToken"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// PodActionResult represents the outcome of a delete or evict action on one pod
+type PodActionResult struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Action    string `json:"action"` // delete, evict
+	Status    string `json:"status"` // deleted, evicted, blocked, notFound, failed
+	Message   string `json:"message,omitempty"`
+}
+
+// DrainEvent represents one step of a node drain, streamed as a live log
+type DrainEvent struct {
+	ID        string `json:"id"` // drain operation ID
+	Node      string `json:"node"`
+	Pod       string `json:"pod,omitempty"` // namespace/name
+	Phase     string `json:"phase"`         // started, cordoned, skipped, blocker, evicting, blocked, evicted, deleted, failed, completed
+	Message   string `json:"message,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// ApplyResult represents the outcome of applying one document of a manifest
+type ApplyResult struct {
+	Index      int          `json:"index"` // position of the document in the manifest
+	APIVersion string       `json:"apiVersion,omitempty"`
+	Kind       string       `json:"kind,omitempty"`
+	Namespace  string       `json:"namespace,omitempty"`
+	Name       string       `json:"name,omitempty"`
+	Action     string       `json:"action,omitempty"` // created, configured, unchanged
+	Diff       string       `json:"diff,omitempty"`   // unified diff of live vs applied object
+	Error      string       `json:"error,omitempty"`
+	Causes     []ApplyCause `json:"causes,omitempty"`
+}
+
+// ApplyCause represents a field-level validation error reported by the API server
+type ApplyCause struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ApplyPlan represents the result of a dry-run apply
+type ApplyPlan struct {
+	DryRun    bool          `json:"dryRun"`
+	Results   []ApplyResult `json:"results"`
+	Token     string        `json:"confirmationToken,omitempty"` // set when every document passed the dry run
+	ExpiresAt *time.Time    `json:"expiresAt,omitempty"`
+}
+
+// EditRecord represents one change made through kub, kept in the local edit history
+type EditRecord struct {
+	ID              string       `json:"id"`
+	Timestamp       time.Time    `json:"timestamp"`
+	User            string       `json:"user"` // authenticated proxy user or client address
+	Context         string       `json:"context"`
+	Kind            string       `json:"kind"`
+	Namespace       string       `json:"namespace,omitempty"`
+	Name            string       `json:"name"`
+	ResourceVersion string       `json:"resourceVersion,omitempty"` // version after the edit
+	Changes         []EditChange `json:"changes"`
+}
+
+// EditChange represents a change to a single key. Old is nil when the key was
+// added and New is nil when it was removed.
+type EditChange struct {
+	Field string  `json:"field"` // data, labels, annotations
+	Key   string  `json:"key"`
+	Old   *string `json:"old"`
+	New   *string `json:"new"`
+}
+
+// ConfigMapConflict is returned when a ConfigMap changed since the edited version
+type ConfigMapConflict struct {
+	ResourceVersion string                 `json:"resourceVersion"` // current version to retry against
+	Keys            []ConfigMapKeyConflict `json:"keys"`
+}
+
+// ConfigMapKeyConflict is the three-way view of one edited key. Base is the
+// value the edit started from, Theirs the current value and Mine the submitted one.
+type ConfigMapKeyConflict struct {
+	Key         string  `json:"key"`
+	Base        *string `json:"base"`
+	Theirs      *string `json:"theirs"`
+	Mine        *string `json:"mine"`
+	Conflicting bool    `json:"conflicting"`          // changed on both sides to different values
+	TheirsDiff  string  `json:"theirsDiff,omitempty"` // unified diff base -> theirs
+	MineDiff    string  `json:"mineDiff,omitempty"`   // unified diff base -> mine
+}
+
+// ConfigMapUpdateResult represents the outcome of a ConfigMap update
+type ConfigMapUpdateResult struct {
+	ConfigMap     ConfigMap         `json:"configMap"`
+	Edit          *EditRecord       `json:"edit,omitempty"`
+	Restarted     []string          `json:"restarted,omitempty"`     // deployments rollout-restarted
+	RestartErrors map[string]string `json:"restartErrors,omitempty"` // deployment -> error ("*" if consumers could not be listed)
+}
+
+// MetadataPatchResult represents the outcome of a label/annotation patch on one object
+type MetadataPatchResult struct {
+	Namespace string      `json:"namespace,omitempty"`
+	Name      string      `json:"name"`
+	Status    string      `json:"status"` // patched, unchanged, notFound, invalid, conflict, failed
+	Message   string      `json:"message,omitempty"`
+	Edit      *EditRecord `json:"edit,omitempty"` // history entry, its ID can be used to undo
+}
+
+// CronJob represents a Kubernetes cronjob
+type CronJob struct {
+	Name               string            `json:"name"`
+	Namespace          string            `json:"namespace"`
+	Schedule           string            `json:"schedule"`
+	TimeZone           string            `json:"timeZone,omitempty"`
+	Suspend            bool              `json:"suspend"`
+	Active             int               `json:"active"` // running jobs
+	LastScheduleTime   *time.Time        `json:"lastScheduleTime,omitempty"`
+	LastSuccessfulTime *time.Time        `json:"lastSuccessfulTime,omitempty"`
+	Labels             map[string]string `json:"labels,omitempty"`
+	Age                string            `json:"age"`
+	CreatedAt          time.Time         `json:"createdAt"`
+}
+
+// CronJobTrigger represents a job created manually from a cronjob
+type CronJobTrigger struct {
+	Namespace string `json:"namespace"`
+	CronJob   string `json:"cronJob"`
+	Job       string `json:"job"`
+	LogsURL   string `json:"logsUrl"` // /ws/logs endpoint following the job's pods
+}
+
+// LogSearchResult is one record of a streamed log search: a matching line, a
+// context line around a match, or the final summary
+type LogSearchResult struct {
+	Type         string     `json:"type"` // match, context, summary
+	LineNumber   int        `json:"lineNumber,omitempty"`
+	Timestamp    *time.Time `json:"timestamp,omitempty"`
+	Text         string     `json:"text,omitempty"`
+	Matches      int        `json:"matches,omitempty"`      // summary only
+	LinesScanned int        `json:"linesScanned,omitempty"` // summary only
+	LimitReached bool       `json:"limitReached,omitempty"` // summary only: the search stopped at the match limit
+}
+
+// LogEntry represents a log line parsed as JSON or logfmt
+type LogEntry struct {
+	Format    string                 `json:"format"` // json, logfmt, text
+	Timestamp *time.Time             `json:"timestamp,omitempty"`
+	Level     string                 `json:"level,omitempty"` // trace, debug, info, warn, error, fatal
+	Message   string                 `json:"message,omitempty"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+}
+
+// ContainerRestart describes the terminated instance of a restarted container
+type ContainerRestart struct {
+	RestartCount int32      `json:"restartCount"`
+	ExitCode     int32      `json:"exitCode"`
+	Signal       int32      `json:"signal,omitempty"`
+	Reason       string     `json:"reason,omitempty"` // e.g. Error, OOMKilled
+	Message      string     `json:"message,omitempty"`
+	FinishedAt   *time.Time `json:"finishedAt,omitempty"`
+}
+
+// ArchivedContainer describes the logs of a container kept in the local log archive
+type ArchivedContainer struct {
+	Namespace string    `json:"namespace"`
+	Pod       string    `json:"pod"`
+	Container string    `json:"container"`
+	First     time.Time `json:"first"` // time of the oldest archived line
+	Last      time.Time `json:"last"`
+	Bytes     int64     `json:"bytes"` // compressed size on disk
+	Segments  int       `json:"segments"`
+}
+
+// LogAlert is raised when a log alert rule matched often enough within its window
+type LogAlert struct {
+	ID         string    `json:"id"`
+	Rule       string    `json:"rule"`
+	Namespace  string    `json:"namespace"`
+	Pod        string    `json:"pod"`
+	Container  string    `json:"container"`
+	Pattern    string    `json:"pattern"`
+	Matches    int       `json:"matches"` // matches within the window
+	Window     string    `json:"window"`
+	FirstMatch time.Time `json:"firstMatch"`
+	LastMatch  time.Time `json:"lastMatch"`
+	Line       string    `json:"line"`                 // the last matching line
+	Suppressed int       `json:"suppressed,omitempty"` // matches during the previous cooldown
+	Text       string    `json:"text"`                 // one-line summary, also used by chat webhooks
+}
+
+// MetricsPoint is one sample of a metrics time series. Points averaged
+// from several samples also carry the highest of them.
+type MetricsPoint struct {
+	Timestamp   int64 `json:"timestamp"`           // unix milliseconds
+	CPUUsage    int64 `json:"cpuUsage"`            // millicores
+	MemoryUsage int64 `json:"memoryUsage"`         // bytes
+	CPUMax      int64 `json:"cpuMax,omitempty"`    // 0 = not averaged, see CPUPeak
+	MemoryMax   int64 `json:"memoryMax,omitempty"` // 0 = not averaged, see MemoryPeak
+}
+
+// CPUPeak is the highest CPU usage the point covers
+func (p MetricsPoint) CPUPeak() int64 {
+	if p.CPUMax > p.CPUUsage {
+		return p.CPUMax
+	}
+	return p.CPUUsage
+}
+
+// MemoryPeak is the highest memory usage the point covers
+func (p MetricsPoint) MemoryPeak() int64 {
+	if p.MemoryMax > p.MemoryUsage {
+		return p.MemoryMax
+	}
+	return p.MemoryUsage
+}
+
+// MetricsSeries is the CPU and memory history of a node, pod or namespace
+type MetricsSeries struct {
+	Kind      string         `json:"kind"` // node, pod, namespace
+	Name      string         `json:"name"`
+	Namespace string         `json:"namespace,omitempty"` // set for pods
+	Points    []MetricsPoint `json:"points"`
+}
+
+// MetricsHistory is the answer to a metrics history query
+type MetricsHistory struct {
+	From   int64           `json:"from"` // unix milliseconds
+	To     int64           `json:"to"`
+	Step   int64           `json:"step"` // milliseconds between points
+	Series []MetricsSeries `json:"series"`
+}
+
+// PodIO is the network and disk throughput of a pod, in bytes per second
+type PodIO struct {
+	Name            string  `json:"name"`
+	Namespace       string  `json:"namespace"`
+	NetworkReceive  float64 `json:"networkReceive"`
+	NetworkTransmit float64 `json:"networkTransmit"`
+	DiskRead        float64 `json:"diskRead"`
+	DiskWrite       float64 `json:"diskWrite"`
+}
+
+// ResourceRecommendation is the usage of one resource of a workload's pods
+// next to the current and suggested request and limit per pod. CPU values
+// are millicores, memory values bytes; 0 means unset. P50 and P95 are taken
+// from points at the resolution of Recommendations.Step; Max is the highest
+// sample within those points.
+type ResourceRecommendation struct {
+	P50              int64 `json:"p50"`
+	P95              int64 `json:"p95"`
+	Max              int64 `json:"max"`
+	Request          int64 `json:"request"`
+	Limit            int64 `json:"limit"`
+	SuggestedRequest int64 `json:"suggestedRequest"`
+	SuggestedLimit   int64 `json:"suggestedLimit"`
+}
+
+// Recommendation is the right-sizing advice for one workload
+type Recommendation struct {
+	Kind          string                 `json:"kind"` // Deployment, StatefulSet, DaemonSet, Job, ... or Pod
+	Name          string                 `json:"name"`
+	Namespace     string                 `json:"namespace"`
+	Pods          int                    `json:"pods"`
+	Containers    int                    `json:"containers"` // per pod; suggestions are for the whole pod
+	Samples       int                    `json:"samples"`
+	CPU           ResourceRecommendation `json:"cpu"`
+	Memory        ResourceRecommendation `json:"memory"`
+	OOMKilled     bool                   `json:"oomKilled"`
+	Flags         []string               `json:"flags,omitempty"`
+	SavingsCPU    float64                `json:"savingsCpu"`    // cores
+	SavingsMemory float64                `json:"savingsMemory"` // GiB
+}
+
+// Recommendations is the answer to a right-sizing query
+type Recommendations struct {
+	From          int64            `json:"from"` // unix milliseconds
+	To            int64            `json:"to"`
+	Step          int64            `json:"step"` // milliseconds between the usage points
+	Workloads     []Recommendation `json:"workloads"`
+	SavingsCPU    float64          `json:"savingsCpu"`    // cores
+	SavingsMemory float64          `json:"savingsMemory"` // GiB
+}
+
+// NodePod is a pod scheduled on a node with what it reserves there
+type NodePod struct {
+	Name          string `json:"name"`
+	Namespace     string `json:"namespace"`
+	CPURequest    int64  `json:"cpuRequest"`    // millicores
+	CPULimit      int64  `json:"cpuLimit"`      // millicores
+	MemoryRequest int64  `json:"memoryRequest"` // bytes
+	MemoryLimit   int64  `json:"memoryLimit"`   // bytes
+}
@@ -0,0 +1,270 @@
+// Package rightsizing compares the usage history of workloads with their
+// requests and limits and suggests better ones.
+package rightsizing
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/krzyzao/kub/internal/models"
+)
+
+// Recommendation flags
+const (
+	FlagOverProvisioned  = "overProvisioned"  // p95 usage well below the request
+	FlagUnderProvisioned = "underProvisioned" // usage near the limit or OOM kills
+	FlagNoRequests       = "noRequests"       // CPU or memory request unset
+	FlagInsufficientData = "insufficientData" // too few samples to judge
+)
+
+const (
+	// Suggested requests are p95 usage plus requestMargin; suggested limits
+	// are max usage plus limitMargin
+	requestMargin = 0.15
+	limitMargin   = 0.3
+	// Over-provisioned: p95 below overThreshold of the request.
+	// Under-provisioned: max above underThreshold of the limit.
+	overThreshold  = 0.5
+	underThreshold = 0.9
+	// Workloads with fewer samples are not flagged or given suggestions
+	minSamples = 12
+
+	minCPU    = 10       // millicores
+	cpuRound  = 5        // millicores
+	minMemory = 16 << 20 // bytes
+	memRound  = 1 << 20  // bytes
+	gib       = 1 << 30
+)
+
+type workload struct {
+	rec     models.Recommendation
+	newest  models.Pod
+	pods    map[string]bool
+	cpu     []int64
+	memory  []int64
+	cpuPeak int64 // highest sample, which averaged points can hide
+	memPeak int64
+	oomKill bool
+}
+
+// Recommend groups pods and the usage series of current and past pods by
+// workload and computes a recommendation for each. Series of pods that no
+// longer exist are matched to workloads by name. Requests and limits are
+// per pod; savings are summed over the current pods.
+func Recommend(pods []models.Pod, series []models.MetricsSeries) models.Recommendations {
+	workloads := make(map[string]*workload)
+	podWorkload := make(map[string]*workload)
+	for _, p := range pods {
+		if p.Phase == "Succeeded" || p.Phase == "Failed" {
+			continue
+		}
+		kind, name := owner(p)
+		key := p.Namespace + "/" + kind + "/" + name
+		w := workloads[key]
+		if w == nil {
+			w = &workload{
+				rec:  models.Recommendation{Kind: kind, Name: name, Namespace: p.Namespace},
+				pods: make(map[string]bool),
+			}
+			workloads[key] = w
+		}
+		w.pods[p.Name] = true
+		if w.newest.Name == "" || p.CreatedAt.After(w.newest.CreatedAt) {
+			w.newest = p
+		}
+		for _, c := range p.Containers {
+			if c.State == "OOMKilled" || c.LastTerminationReason == "OOMKilled" {
+				w.oomKill = true
+			}
+		}
+		podWorkload[p.Namespace+"/"+p.Name] = w
+	}
+
+	for _, s := range series {
+		if s.Kind != "pod" {
+			continue
+		}
+		w := podWorkload[s.Namespace+"/"+s.Name]
+		if w == nil {
+			w = matchByName(workloads, s.Namespace, s.Name)
+		}
+		if w == nil {
+			continue
+		}
+		for _, p := range s.Points {
+			w.cpu = append(w.cpu, p.CPUUsage)
+			w.memory = append(w.memory, p.MemoryUsage)
+			w.cpuPeak = max(w.cpuPeak, p.CPUPeak())
+			w.memPeak = max(w.memPeak, p.MemoryPeak())
+		}
+	}
+
+	result := models.Recommendations{Workloads: make([]models.Recommendation, 0, len(workloads))}
+	for _, w := range workloads {
+		rec := w.recommend()
+		result.SavingsCPU += rec.SavingsCPU
+		result.SavingsMemory += rec.SavingsMemory
+		result.Workloads = append(result.Workloads, rec)
+	}
+
+	// Largest savings first, then by name
+	sort.Slice(result.Workloads, func(i, j int) bool {
+		a, b := result.Workloads[i], result.Workloads[j]
+		if sa, sb := a.SavingsCPU+a.SavingsMemory, b.SavingsCPU+b.SavingsMemory; sa != sb {
+			return sa > sb
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return result
+}
+
+func (w *workload) recommend() models.Recommendation {
+	rec := w.rec
+	rec.Pods = len(w.pods)
+	rec.Containers = len(w.newest.Containers)
+	rec.Samples = len(w.cpu)
+	rec.OOMKilled = w.oomKill
+
+	rec.CPU = models.ResourceRecommendation{Request: w.newest.CPURequest, Limit: w.newest.CPULimit}
+	rec.Memory = models.ResourceRecommendation{Request: w.newest.MemoryRequest, Limit: w.newest.MemoryLimit}
+
+	if rec.CPU.Request == 0 || rec.Memory.Request == 0 {
+		rec.Flags = append(rec.Flags, FlagNoRequests)
+	}
+	if rec.Samples < minSamples {
+		rec.Flags = append(rec.Flags, FlagInsufficientData)
+		return rec
+	}
+
+	rec.CPU.P50, rec.CPU.P95 = percentiles(w.cpu)
+	rec.Memory.P50, rec.Memory.P95 = percentiles(w.memory)
+	rec.CPU.Max = w.cpuPeak
+	rec.Memory.Max = w.memPeak
+
+	rec.CPU.SuggestedRequest = roundUp(withMargin(rec.CPU.P95, requestMargin), cpuRound, minCPU)
+	rec.Memory.SuggestedRequest = roundUp(withMargin(rec.Memory.P95, requestMargin), memRound, minMemory)
+	// CPU limits are only suggested where one is set, since CPU is throttled
+	// rather than killed
+	if rec.CPU.Limit > 0 {
+		rec.CPU.SuggestedLimit = max64(roundUp(withMargin(rec.CPU.Max, limitMargin), cpuRound, minCPU), rec.CPU.SuggestedRequest)
+	}
+	rec.Memory.SuggestedLimit = max64(roundUp(withMargin(rec.Memory.Max, limitMargin), memRound, minMemory), rec.Memory.SuggestedRequest)
+	if w.oomKill && rec.Memory.Limit > 0 {
+		// The recorded usage is capped by the limit that killed it, so the
+		// request is not lowered and the limit is raised past it
+		rec.Memory.SuggestedRequest = max64(rec.Memory.SuggestedRequest, rec.Memory.Request)
+		rec.Memory.SuggestedLimit = max64(rec.Memory.SuggestedLimit, roundUp(withMargin(rec.Memory.Limit, limitMargin), memRound, minMemory))
+	}
+
+	over := overProvisioned(rec.CPU) || overProvisioned(rec.Memory)
+	under := w.oomKill || underProvisioned(rec.CPU) || underProvisioned(rec.Memory)
+	if under {
+		rec.Flags = append(rec.Flags, FlagUnderProvisioned)
+	} else if over {
+		rec.Flags = append(rec.Flags, FlagOverProvisioned)
+	}
+
+	// Savings are the reservations given back across the current pods
+	if over && !under {
+		if d := rec.CPU.Request - rec.CPU.SuggestedRequest; d > 0 && overProvisioned(rec.CPU) {
+			rec.SavingsCPU = float64(d*int64(rec.Pods)) / 1000
+		}
+		if d := rec.Memory.Request - rec.Memory.SuggestedRequest; d > 0 && overProvisioned(rec.Memory) {
+			rec.SavingsMemory = float64(d*int64(rec.Pods)) / gib
+		}
+	}
+	return rec
+}
+
+func overProvisioned(r models.ResourceRecommendation) bool {
+	return r.Request > 0 && float64(r.P95) < float64(r.Request)*overThreshold
+}
+
+func underProvisioned(r models.ResourceRecommendation) bool {
+	return r.Limit > 0 && float64(r.Max) >= float64(r.Limit)*underThreshold
+}
+
+// owner returns the workload a pod belongs to. ReplicaSets are resolved to
+// their Deployment through the pod-template-hash label; pods without a
+// controller are their own workload.
+func owner(p models.Pod) (kind, name string) {
+	for _, ref := range p.OwnerReferences {
+		switch ref.Kind {
+		case "ReplicaSet":
+			if hash := p.Labels["pod-template-hash"]; hash != "" && strings.HasSuffix(ref.Name, "-"+hash) {
+				return "Deployment", strings.TrimSuffix(ref.Name, "-"+hash)
+			}
+			return ref.Kind, ref.Name
+		case "StatefulSet", "DaemonSet", "Job", "ReplicationController":
+			return ref.Kind, ref.Name
+		}
+	}
+	return "Pod", p.Name
+}
+
+// matchByName finds the workload of a pod that no longer exists from the
+// names controllers give their pods: <deployment>-<hash>-<id>,
+// <statefulset>-<ordinal> and <daemonset|job>-<id>. The longest matching
+// workload name wins.
+func matchByName(workloads map[string]*workload, namespace, pod string) *workload {
+	var best *workload
+	for _, w := range workloads {
+		if w.rec.Namespace != namespace || !strings.HasPrefix(pod, w.rec.Name+"-") {
+			continue
+		}
+		suffix := strings.Split(strings.TrimPrefix(pod, w.rec.Name+"-"), "-")
+		var ok bool
+		switch w.rec.Kind {
+		case "Deployment":
+			ok = len(suffix) == 2
+		case "StatefulSet":
+			ok = len(suffix) == 1 && strings.Trim(suffix[0], "0123456789") == ""
+		case "DaemonSet", "Job", "ReplicaSet", "ReplicationController":
+			ok = len(suffix) == 1
+		}
+		if ok && (best == nil || len(w.rec.Name) > len(best.rec.Name)) {
+			best = w
+		}
+	}
+	return best
+}
+
+// percentiles returns the p50 and p95 of values (nearest rank)
+func percentiles(values []int64) (p50, p95 int64) {
+	sorted := append([]int64(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := func(p float64) int64 {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return sorted[i]
+	}
+	return rank(0.5), rank(0.95)
+}
+
+func withMargin(v int64, margin float64) int64 {
+	return int64(math.Ceil(float64(v) * (1 + margin)))
+}
+
+// roundUp rounds v up to a multiple of step, at least min
+func roundUp(v, step, min int64) int64 {
+	if v < min {
+		return min
+	}
+	if rem := v % step; rem != 0 {
+		v += step - rem
+	}
+	return v
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
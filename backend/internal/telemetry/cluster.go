@@ -0,0 +1,78 @@
+package telemetry
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/krzyzao/kub/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Time allowed to compute the cluster summary during a scrape
+const summaryTimeout = 10 * time.Second
+
+var (
+	summaryUp = prometheus.NewDesc(namespace+"_cluster_summary_up",
+		"Whether the cluster summary could be fetched.", nil, nil)
+	nodesDesc = prometheus.NewDesc(namespace+"_cluster_nodes",
+		"Nodes in the cluster by readiness.", []string{"ready"}, nil)
+	podsDesc = prometheus.NewDesc(namespace+"_cluster_pods",
+		"Pods in the cluster by phase (total = all phases).", []string{"phase"}, nil)
+	cpuDesc = prometheus.NewDesc(namespace+"_cluster_cpu_millicores",
+		"Allocatable (total) and used CPU of the cluster.", []string{"type"}, nil)
+	memoryDesc = prometheus.NewDesc(namespace+"_cluster_memory_bytes",
+		"Allocatable (total) and used memory of the cluster.", []string{"type"}, nil)
+	cpuPercentDesc = prometheus.NewDesc(namespace+"_cluster_cpu_percent",
+		"Used CPU as a percentage of the total.", nil, nil)
+	memoryPercentDesc = prometheus.NewDesc(namespace+"_cluster_memory_percent",
+		"Used memory as a percentage of the total.", nil, nil)
+)
+
+// ClusterCollector exports the ClusterSummary figures, fetched at scrape time
+// for the current context
+type ClusterCollector struct {
+	summary func(ctx context.Context) (*models.ClusterSummary, error)
+}
+
+// NewClusterCollector creates a collector reading the summary from fn
+func NewClusterCollector(fn func(ctx context.Context) (*models.ClusterSummary, error)) *ClusterCollector {
+	return &ClusterCollector{summary: fn}
+}
+
+// Describe implements prometheus.Collector
+func (c *ClusterCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{summaryUp, nodesDesc, podsDesc, cpuDesc, memoryDesc, cpuPercentDesc, memoryPercentDesc} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector
+func (c *ClusterCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+	defer cancel()
+
+	s, err := c.summary(ctx)
+	if err != nil {
+		log.Printf("Failed to get cluster summary for metrics: %v", err)
+		ch <- prometheus.MustNewConstMetric(summaryUp, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(summaryUp, prometheus.GaugeValue, 1)
+
+	gauge := func(desc *prometheus.Desc, value float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+	}
+	gauge(nodesDesc, float64(s.ReadyNodes), "true")
+	gauge(nodesDesc, float64(s.TotalNodes-s.ReadyNodes), "false")
+	gauge(podsDesc, float64(s.TotalPods), "total")
+	gauge(podsDesc, float64(s.RunningPods), "Running")
+	gauge(podsDesc, float64(s.PendingPods), "Pending")
+	gauge(podsDesc, float64(s.FailedPods), "Failed")
+	gauge(cpuDesc, float64(s.TotalCPU), "total")
+	gauge(cpuDesc, float64(s.UsedCPU), "used")
+	gauge(memoryDesc, float64(s.TotalMemory), "total")
+	gauge(memoryDesc, float64(s.UsedMemory), "used")
+	gauge(cpuPercentDesc, s.CPUPercent)
+	gauge(memoryPercentDesc, s.MemoryPercent)
+}
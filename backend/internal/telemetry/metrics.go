@@ -0,0 +1,137 @@
+// Package telemetry exposes kub's own metrics and the cluster summary in the
+// Prometheus format.
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+)
+
+const namespace = "kub"
+
+// Registry holds every kub metric plus the Go runtime and process collectors
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests counts served requests by route pattern, method and status
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	// HTTPDuration observes request latencies by route pattern and method.
+	// WebSocket connections are not observed.
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latencies by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	// WebSocketClients is the number of open WebSocket connections by endpoint
+	WebSocketClients = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "websocket_clients",
+		Help:      "Open WebSocket connections by endpoint.",
+	}, []string{"endpoint"})
+
+	// WatchRestarts counts watches started again after they ended or failed
+	WatchRestarts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "watch_restarts_total",
+		Help:      "Kubernetes watches restarted after ending or failing.",
+	}, []string{"watch"})
+
+	// RateLimitRejections counts requests refused by the rate limiter
+	RateLimitRejections = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests rejected by the per-client rate limiter.",
+	})
+
+	apiserverLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "apiserver_request_duration_seconds",
+		Help:      "Kubernetes API server request latencies by verb.",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"verb"})
+
+	apiserverRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "apiserver_requests_total",
+		Help:      "Kubernetes API server requests by method and status code.",
+	}, []string{"method", "code"})
+)
+
+// Broadcast queue depth, read at scrape time
+var (
+	queueMu    sync.Mutex
+	queueDepth func() int
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		WebSocketClients,
+		WatchRestarts,
+		RateLimitRejections,
+		apiserverLatency,
+		apiserverRequests,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "broadcast_queue_depth",
+			Help:      "Messages waiting to be sent to /ws clients.",
+		}, func() float64 {
+			queueMu.Lock()
+			defer queueMu.Unlock()
+			if queueDepth == nil {
+				return 0
+			}
+			return float64(queueDepth())
+		}),
+	)
+
+	clientmetrics.Register(clientmetrics.RegisterOpts{
+		RequestLatency: latencyAdapter{},
+		RequestResult:  resultAdapter{},
+	})
+}
+
+// SetBroadcastQueue sets the function reporting the broadcast queue depth
+func SetBroadcastQueue(depth func() int) {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	queueDepth = depth
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// latencyAdapter receives client-go request latencies. The URL is not used
+// as a label since it contains object names.
+type latencyAdapter struct{}
+
+func (latencyAdapter) Observe(_ context.Context, verb string, _ url.URL, latency time.Duration) {
+	apiserverLatency.WithLabelValues(verb).Observe(latency.Seconds())
+}
+
+// resultAdapter receives client-go request results
+type resultAdapter struct{}
+
+func (resultAdapter) Increment(_ context.Context, code, method, _ string) {
+	apiserverRequests.WithLabelValues(method, code).Inc()
+}
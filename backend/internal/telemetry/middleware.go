@@ -0,0 +1,46 @@
+package telemetry
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Middleware records request counts and latencies by chi route pattern, so
+// /api/pods/{namespace}/{name} is one series however many pods are viewed
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				route = pattern
+			}
+		}
+
+		code := ww.Status()
+		upgraded := false
+		if code == 0 {
+			// Hijacked WebSocket upgrades bypass WriteHeader
+			code = http.StatusOK
+			if r.Header.Get("Upgrade") != "" {
+				code = http.StatusSwitchingProtocols
+				upgraded = true
+			}
+		}
+
+		HTTPRequests.WithLabelValues(route, r.Method, strconv.Itoa(code)).Inc()
+		// A WebSocket's duration is the connection's lifetime, not a latency;
+		// open connections are counted by WebSocketClients instead
+		if !upgraded {
+			HTTPDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+		}
+	})
+}
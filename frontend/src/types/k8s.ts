@@ -131,6 +131,19 @@ export interface Node {
   cpuPercent: number;
   memoryPercent: number;
   podCount: number;
+  podAllocatable: number;
+  cpuRequests: number;
+  cpuLimits: number;
+  memoryRequests: number;
+  memoryLimits: number;
+  cpuRequestPercent: number;
+  memoryRequestPercent: number;
+  cpuOvercommit: number;
+  memoryOvercommit: number;
+  cpuSchedulable: number;
+  memorySchedulable: number;
+  podsSchedulable: number;
+  pods?: NodePod[];
   age: string;
   createdAt: string;
   conditions: NodeCondition[];
@@ -174,6 +187,15 @@ export interface PodEvent {
   timestamp: number;
 }
 
+export interface NodePod {
+  name: string;
+  namespace: string;
+  cpuRequest: number;
+  cpuLimit: number;
+  memoryRequest: number;
+  memoryLimit: number;
+}
+
 export interface NodeMetrics {
   name: string;
   cpuUsage: number;
@@ -182,11 +204,26 @@ export interface NodeMetrics {
   memPercent: number;
 }
 
+export interface ContainerMetrics {
+  name: string;
+  cpuUsage: number;
+  memoryUsage: number;
+  cpuRequest: number;
+  cpuLimit: number;
+  memoryRequest: number;
+  memoryLimit: number;
+  cpuRequestPercent?: number;
+  cpuLimitPercent?: number;
+  memRequestPercent?: number;
+  memLimitPercent?: number;
+}
+
 export interface PodMetrics {
   name: string;
   namespace: string;
   cpuUsage: number;
   memoryUsage: number;
+  containers?: ContainerMetrics[];
 }
 
 export interface MetricsSnapshot {